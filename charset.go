@@ -0,0 +1,73 @@
+package search
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// utf8BOM is the byte order mark in UTF-8
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// minCharsetConfidence is the confidence below which a sniffed charset is not trusted
+const minCharsetConfidence = 50
+
+// isText reports if documents of this mime type are text, which may need transcoding
+func isText(mimeType string) bool {
+	base := baseMimeType(mimeType)
+	if strings.HasPrefix(base, "text/") {
+		return true
+	}
+	for mtype := mimetype.Lookup(base); mtype != nil; mtype = mtype.Parent() {
+		if mtype.Is("text/plain") {
+			return true
+		}
+	}
+	return false
+}
+
+// toUTF8 transcodes body to UTF-8. Its charset is taken from the byte order
+// mark, the charset parameter of contentType or a <meta charset> tag, or else
+// sniffed from the content. A declared UTF-8 is sniffed too when the body is
+// not valid UTF-8. The byte order mark is dropped, bodies in an unknown charset
+// are returned as is.
+func toUTF8(body []byte, contentType string) []byte {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	// the <meta charset> tags are not certain, windows-1252 is the default
+	// when there is none
+	declared := certain || name != "windows-1252"
+	if name == "utf-8" && !utf8.Valid(body) {
+		declared = false
+	}
+	if !declared {
+		if utf8.Valid(body) {
+			return body
+		}
+		result, err := chardet.NewTextDetector().DetectBest(body)
+		if err != nil || result.Confidence < minCharsetConfidence {
+			return body
+		}
+		// chardet names GB18030 "GB-18030", which is not a known label
+		label := strings.Replace(strings.ToLower(result.Charset), "gb-18030", "gb18030", 1)
+		if enc, err = htmlindex.Get(label); err != nil {
+			return body
+		}
+		if name, err = htmlindex.Name(enc); err != nil {
+			return body
+		}
+	}
+	if name == "utf-8" {
+		return bytes.TrimPrefix(body, utf8BOM)
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body
+	}
+	return bytes.TrimPrefix(decoded, utf8BOM)
+}
@@ -0,0 +1,73 @@
+package search
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// encode encodes s with enc
+func encode(enc encoding.Encoding, s string) []byte {
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestToUTF8(t *testing.T) {
+	const chinese = "搜索引擎可以帮助用户在互联网上快速找到需要的信息，我们每天都会使用它来查找新闻、文章和各种资料。"
+	page := func(charset, text string) string {
+		return `<html><head><meta charset="` + charset + `"><title>T</title></head><body>` + text + `</body></html>`
+	}
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"ascii", []byte("plain text"), "text/plain", "plain text"},
+		{"utf-8", []byte("中文内容"), "text/plain", "中文内容"},
+		{"utf-8 byte order mark", []byte("\xef\xbb\xbf中文内容"), "text/plain", "中文内容"},
+		{"utf-16 byte order mark", encode(unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "中文内容"), "text/plain", "中文内容"},
+		{"content type", encode(simplifiedchinese.GBK, "中文内容"), "text/plain; charset=gbk", "中文内容"},
+		{"meta gbk", encode(simplifiedchinese.GBK, page("gbk", "中文")), "text/html", page("gbk", "中文")},
+		{"meta big5", encode(traditionalchinese.Big5, page("big5", "繁體中文")), "text/html", page("big5", "繁體中文")},
+		{"meta shift_jis", encode(japanese.ShiftJIS, page("shift_jis", "日本語")), "text/html", page("shift_jis", "日本語")},
+		{"meta utf-8", []byte(page("utf-8", "中文")), "text/html", page("utf-8", "中文")},
+		{"wrong meta utf-8", encode(simplifiedchinese.GB18030, page("utf-8", chinese)), "text/html", page("utf-8", chinese)},
+		{"wrong content type", encode(simplifiedchinese.GB18030, chinese), "text/plain; charset=utf-8", chinese},
+		{"sniffed", encode(simplifiedchinese.GB18030, chinese), "text/plain", chinese},
+		{"unknown", []byte{0xfe, 0xfd}, "text/plain", "\xfe\xfd"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := string(toUTF8(test.body, test.contentType)); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsText(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		want     bool
+	}{
+		{"text/plain; charset=gbk", true},
+		{"text/html", true},
+		{"text/x-unknown", true},
+		{"application/json", true},
+		{"application/pdf", false},
+		{"image/png", false},
+	}
+	for _, test := range tests {
+		if got := isText(test.mimeType); got != test.want {
+			t.Errorf("isText(%q) = %v, want %v", test.mimeType, got, test.want)
+		}
+	}
+}
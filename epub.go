@@ -0,0 +1,170 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+// epubExtractor indexes an EPUB book as a record holding its metadata and
+// table of contents, and one record per chapter at <path>#<chapter>
+type epubExtractor struct{}
+
+func init() {
+	RegisterExtractor("application/epub+zip", epubExtractor{})
+}
+
+// epubContainer is META-INF/container.xml, which locates the package document
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the OPF package document: the book metadata, its files and their reading order
+type epubPackage struct {
+	Metadata struct {
+		Title       []string `xml:"title"`
+		Creator     []string `xml:"creator"`
+		Language    []string `xml:"language"`
+		Description []string `xml:"description"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID        string `xml:"id,attr"`
+		Href      string `xml:"href,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef string `xml:"idref,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// first returns the first non empty value of values
+func first(values []string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Extract indexes the book only, its chapters are indexed by ExtractParts
+func (e epubExtractor) Extract(record indexer.Record) (bool, error) {
+	return e.ExtractParts(record, nil)
+}
+
+// ExtractParts indexes the book and its chapters, in reading order
+func (epubExtractor) ExtractParts(record indexer.Record, part func(name string) indexer.Record) (bool, error) {
+	body := record.Body()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return false, err
+	}
+	files := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+	decode := func(name string, v interface{}) error {
+		file, ok := files[name]
+		if !ok {
+			return fmt.Errorf("missing %v", name)
+		}
+		return readZipXML(file, func(r io.Reader) error {
+			return xml.NewDecoder(r).Decode(v)
+		})
+	}
+
+	var container epubContainer
+	if err := decode("META-INF/container.xml", &container); err != nil {
+		return false, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return false, fmt.Errorf("no package document")
+	}
+	opf := container.Rootfiles[0].FullPath
+	var pkg epubPackage
+	if err := decode(opf, &pkg); err != nil {
+		return false, err
+	}
+
+	title := first(pkg.Metadata.Title)
+	if title == "" {
+		title = path.Base(record.Path())
+	}
+	author := first(pkg.Metadata.Creator)
+	lang := strings.ToLower(first(pkg.Metadata.Language))
+	description := first(pkg.Metadata.Description)
+	record.SetTitle(title)
+	record.SetMeta("author", author)
+	record.SetMeta("lang", lang)
+	record.SetMeta("description", description)
+
+	hrefs := make(map[string]string)
+	for _, item := range pkg.Manifest {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "text/html" {
+			hrefs[item.ID] = item.Href
+		}
+	}
+
+	toc := []string{title, author, description}
+	for n, itemref := range pkg.Spine {
+		href, ok := hrefs[itemref.IDRef]
+		if !ok {
+			continue
+		}
+		name, err := url.PathUnescape(href)
+		if err != nil {
+			name = href
+		}
+		file, ok := files[path.Join(path.Dir(opf), name)]
+		if !ok {
+			continue
+		}
+		var content []byte
+		if err := readZipXML(file, func(r io.Reader) (err error) {
+			content, err = ioutil.ReadAll(r)
+			return err
+		}); err != nil {
+			return false, fmt.Errorf("%v: %v", name, err)
+		}
+
+		chapter, meta, _ := getHtmlMeta(bytes.NewReader(content), "")
+		if headings := meta["headings"]; headings != "" {
+			chapter = strings.SplitN(headings, "\n", 2)[0]
+		}
+		if chapter == "" || chapter == title {
+			chapter = "Chapter " + strconv.Itoa(n+1)
+		}
+		text := htmlText(content)
+		if len(bytes.TrimSpace(text)) == 0 {
+			continue
+		}
+		toc = append(toc, chapter)
+
+		if part == nil {
+			continue
+		}
+		rec := part(href)
+		rec.SetTitle(chapter + " - " + title)
+		rec.SetMeta("author", author)
+		rec.SetMeta("lang", lang)
+		if meta["lang"] != "" {
+			rec.SetMeta("lang", meta["lang"])
+		}
+		rec.SetMeta("headings", meta["headings"])
+		rec.SetBody(text)
+	}
+
+	record.SetBody([]byte(strings.Join(toc, "\n")))
+	return true, nil
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+// testEpub returns a book holding the given chapters, a file name followed by
+// its content, in reading order
+func testEpub(chapters ...string) []byte {
+	var manifest, spine strings.Builder
+	members := []string{
+		"mimetype", "application/epub+zip",
+		"META-INF/container.xml", `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`,
+	}
+	for n := 0; n+1 < len(chapters); n += 2 {
+		id := "c" + chapters[n]
+		manifest.WriteString(`<item id="` + id + `" href="` + chapters[n] + `" media-type="application/xhtml+xml"/>`)
+		spine.WriteString(`<itemref idref="` + id + `"/>`)
+		members = append(members, "OEBPS/"+strings.ReplaceAll(chapters[n], "%20", " "), chapters[n+1])
+	}
+	members = append(members, "OEBPS/content.opf", `<package xmlns:dc="http://purl.org/dc/elements/1.1/">
+		<metadata><dc:title>A book</dc:title><dc:creator>An author</dc:creator>
+		<dc:language>EN</dc:language><dc:description>About it</dc:description></metadata>
+		<manifest>`+manifest.String()+`<item id="css" href="style.css" media-type="text/css"/></manifest>
+		<spine>`+spine.String()+`<itemref idref="css"/><itemref idref="missing"/></spine></package>`)
+	return testZip(members...)
+}
+
+func TestExtractEpubParts(t *testing.T) {
+	book := testEpub(
+		"one.xhtml", `<html><body><h1>The start</h1><p>First text</p></body></html>`,
+		"blank.xhtml", `<html><body> </body></html>`,
+		"chapter%20two.xhtml", `<html lang="fr"><head><title>A book</title></head><body><p>Second text</p></body></html>`,
+	)
+	rec := newTestRecord("/books/a.epub", "")
+	rec.SetBody(book)
+	parts := make(map[string]*testRecord)
+	var names []string
+	ok, err := epubExtractor{}.ExtractParts(rec, func(name string) indexer.Record {
+		part := newTestRecord(rec.Path()+"#"+name, "")
+		parts[name] = part
+		names = append(names, name)
+		return part
+	})
+	if !ok || err != nil {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+
+	if rec.Title() != "A book" || rec.Meta("author") != "An author" || rec.Meta("lang") != "en" || rec.Meta("description") != "About it" {
+		t.Errorf("got title %q and meta %v", rec.Title(), rec.meta)
+	}
+	if toc := string(rec.Body()); toc != "A book\nAn author\nAbout it\nThe start\nChapter 3" {
+		t.Errorf("got table of contents %q", toc)
+	}
+	if want := []string{"one.xhtml", "chapter%20two.xhtml"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("got parts %v, want %v", names, want)
+	}
+
+	tests := []struct {
+		name, title, lang, text string
+	}{
+		{"one.xhtml", "The start - A book", "en", "First text"},
+		{"chapter%20two.xhtml", "Chapter 3 - A book", "fr", "Second text"},
+	}
+	for _, test := range tests {
+		part := parts[test.name]
+		if part.Title() != test.title || part.Meta("lang") != test.lang || part.Meta("author") != "An author" {
+			t.Errorf("%v: got title %q and meta %v", test.name, part.Title(), part.meta)
+		}
+		if body := string(part.Body()); !strings.Contains(body, test.text) || strings.Contains(body, "<") {
+			t.Errorf("%v: got body %q", test.name, body)
+		}
+	}
+
+	// Extract indexes the book record only
+	rec = newTestRecord("/books/a.epub", "")
+	rec.SetBody(book)
+	if ok, err := (epubExtractor{}).Extract(rec); !ok || err != nil || rec.Title() != "A book" {
+		t.Errorf("got %v, %v, title %q", ok, err, rec.Title())
+	}
+}
+
+func TestExtractEpubErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"not an archive", []byte("some text")},
+		{"no container", testZip("mimetype", "application/epub+zip")},
+		{"no package document", testZip("META-INF/container.xml", `<container><rootfiles></rootfiles></container>`)},
+		{"missing package document", testZip("META-INF/container.xml", `<container><rootfiles><rootfile full-path="content.opf"/></rootfiles></container>`)},
+	}
+	for _, test := range tests {
+		rec := newTestRecord("/books/a.epub", "")
+		rec.SetBody(test.body)
+		if ok, err := (epubExtractor{}).Extract(rec); ok || err == nil {
+			t.Errorf("%v: got %v, %v", test.name, ok, err)
+		}
+	}
+}
+
+// recordingHandler is an indexer recording the records indexed and deleted
+type recordingHandler struct {
+	indexer.Handler
+	indexed []string
+	deleted []string
+}
+
+func (h *recordingHandler) Record(path string) indexer.Record {
+	return newTestRecord(path, "")
+}
+
+func (h *recordingHandler) Index(record indexer.Record) {
+	h.indexed = append(h.indexed, record.Path())
+}
+
+func (h *recordingHandler) Delete(path string) error {
+	h.deleted = append(h.deleted, path)
+	return nil
+}
+
+func TestIndexEpub(t *testing.T) {
+	handler := &recordingHandler{}
+	manager := &IndexerManager{indexer: handler, MaxFileSize: 1 << 20}
+	rec := newTestRecord("/books/a.epub", "")
+	rec.SetBody(testEpub("one.xhtml", `<html><body><p>First text</p></body></html>`))
+	rec.SetMimeType("application/epub+zip")
+	rec.SetFullPath("/srv/books/a.epub")
+
+	manager.index(rec)
+
+	// the parts indexed the last time are deleted along with the book first
+	if want := []string{"/books/a.epub"}; !reflect.DeepEqual(handler.deleted, want) {
+		t.Errorf("got deleted %v, want %v", handler.deleted, want)
+	}
+	if want := []string{"/books/a.epub", "/books/a.epub#one.xhtml"}; !reflect.DeepEqual(handler.indexed, want) {
+		t.Errorf("got indexed %v, want %v", handler.indexed, want)
+	}
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// Extractor turns a record whose body holds a raw document into the title,
+// body text and metadata to index. It returns false for documents which must
+// not be indexed, and an error for documents it cannot read.
+type Extractor interface {
+	Extract(record indexer.Record) (bool, error)
+}
+
+// ExtractorFunc adapts a function to the Extractor interface
+type ExtractorFunc func(record indexer.Record) (bool, error)
+
+// Extract calls f(record)
+func (f ExtractorFunc) Extract(record indexer.Record) (bool, error) {
+	return f(record)
+}
+
+// PartsExtractor is implemented by the extractors of documents whose parts are
+// also indexed as records of their own, e.g. the chapters of a book. Besides
+// the record, ExtractParts fills the records returned by part, whose path is
+// the path of the document followed by #name.
+type PartsExtractor interface {
+	Extractor
+	ExtractParts(record indexer.Record, part func(name string) indexer.Record) (bool, error)
+}
+
+var (
+	extractorsLock sync.RWMutex
+	extractors     = make(map[string]Extractor)
+)
+
+// RegisterExtractor sets the extractor of a mime type, which is also used for
+// the mime types descending from it unless they have their own
+func RegisterExtractor(mimeType string, extractor Extractor) {
+	extractorsLock.Lock()
+	extractors[baseMimeType(mimeType)] = extractor
+	extractorsLock.Unlock()
+}
+
+// LookupExtractor returns the extractor of a mime type or of its closest
+// ancestor, nil if the documents of this type cannot be indexed
+func LookupExtractor(mimeType string) Extractor {
+	extractorsLock.RLock()
+	defer extractorsLock.RUnlock()
+
+	base := baseMimeType(mimeType)
+	if extractor, ok := extractors[base]; ok {
+		return extractor
+	}
+	for mtype := mimetype.Lookup(base); mtype != nil; mtype = mtype.Parent() {
+		if extractor, ok := extractors[baseMimeType(mtype.String())]; ok {
+			return extractor
+		}
+	}
+	return nil
+}
+
+// baseMimeType strips the parameters of a mime type
+func baseMimeType(mimeType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+}
+
+func init() {
+	RegisterExtractor("text/plain", ExtractorFunc(extractText))
+	RegisterExtractor("text/html", ExtractorFunc(extractHtml))
+	RegisterExtractor("text/markdown", ExtractorFunc(extractMarkdown))
+	RegisterExtractor("application/pdf", ExtractorFunc(extractPdf))
+}
+
+// extractText indexes plain text as it is, titled by its file name
+func extractText(record indexer.Record) (bool, error) {
+	record.SetTitle(path.Base(record.Path()))
+	return true, nil
+}
+
+// extractHtml indexes the text of a HTML document along with its title and metadata
+func extractHtml(record indexer.Record) (bool, error) {
+	title, meta, _ := getHtmlMeta(bytes.NewReader(record.Body()), path.Base(record.Path()))
+	record.SetTitle(title)
+	for key, value := range meta {
+		record.SetMeta(key, value)
+	}
+	record.SetBody(htmlText(record.Body()))
+	return true, nil
+}
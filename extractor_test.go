@@ -0,0 +1,83 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+func TestLookupExtractor(t *testing.T) {
+	custom := ExtractorFunc(func(record indexer.Record) (bool, error) { return true, nil })
+	RegisterExtractor("Application/X-Custom; version=2", custom)
+	defer func() {
+		extractorsLock.Lock()
+		delete(extractors, "application/x-custom")
+		extractorsLock.Unlock()
+	}()
+
+	tests := []struct {
+		mimeType string
+		want     string
+	}{
+		{"text/plain", "text/plain"},
+		{"text/plain; charset=utf-8", "text/plain"},
+		{" TEXT/HTML ", "text/html"},
+		{"text/markdown", "text/markdown"},
+		{"application/pdf", "application/pdf"},
+		{"application/epub+zip", "application/epub+zip"},
+		{"application/vnd.oasis.opendocument.text", "application/vnd.oasis.opendocument.text"},
+		// the mime types descending from text/plain
+		{"application/json", "text/plain"},
+		{"text/csv", "text/plain"},
+		{"application/x-custom", "application/x-custom"},
+		{"image/png", ""},
+		{"application/octet-stream", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		got := LookupExtractor(test.mimeType)
+		var want Extractor
+		if test.want != "" {
+			want = extractors[test.want]
+		}
+		if (got == nil) != (want == nil) || (got != nil && !sameExtractor(got, want)) {
+			t.Errorf("LookupExtractor(%q) = %T, want the extractor of %q", test.mimeType, got, test.want)
+		}
+	}
+}
+
+// sameExtractor reports if a and b are the same extractor, the functions and
+// the structures holding some are printed as pointers
+func sameExtractor(a, b Extractor) bool {
+	return fmt.Sprintf("%T %v", a, a) == fmt.Sprintf("%T %v", b, b)
+}
+
+func TestExtractText(t *testing.T) {
+	rec := newTestRecord("/docs/notes.txt", "some <text>")
+	if ok, err := extractText(rec); !ok || err != nil {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if rec.Title() != "notes.txt" || string(rec.Body()) != "some <text>" {
+		t.Errorf("got title %q and body %q", rec.Title(), rec.Body())
+	}
+}
+
+func TestExtractHtml(t *testing.T) {
+	rec := newTestRecord("/docs/page.html", `<html lang="fr"><head><title>Page</title>
+		<meta name="description" content="About"></head>
+		<body><h1>Head</h1><p>Some <b>text</b></p><script>code()</script></body></html>`)
+	if ok, err := extractHtml(rec); !ok || err != nil {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if rec.Title() != "Page" {
+		t.Errorf("got title %q", rec.Title())
+	}
+	if rec.Meta("description") != "About" || rec.Meta("lang") != "fr" || rec.Meta("headings") != "Head" {
+		t.Errorf("got meta %v", rec.meta)
+	}
+	if body := string(rec.Body()); strings.Contains(body, "<") || !strings.Contains(body, "Some text") {
+		t.Errorf("got body %q", body)
+	}
+}
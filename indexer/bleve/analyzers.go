@@ -1,14 +1,14 @@
 package bleve
 
 import (
-	"bufio"
 	"embed"
-	"io"
+	"strings"
 
 	"github.com/blevesearch/bleve/v2/analysis"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
 	"github.com/blevesearch/bleve/v2/mapping"
 	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search/query"
 	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer/bleve/sego"
 )
 
@@ -17,73 +17,227 @@ var dicts embed.FS
 
 const defaultDict = "sego/dicts/default.txt"
 
+// SegoQueryName is the name of the analyzer of the queries on the fields
+// analyzed by sego, the same as the sego analyzer without the search mode
+const SegoQueryName = "sego_query"
+
 // SegoTokenizer is the beleve tokenizer for jiebago.
 type SegoTokenizer struct {
-	seg        sego.Segmenter
+	dict       *segoDictionary
 	searchMode bool
+	// hmm recognizes the words missing from the dictionaries
+	hmm bool
+	// pos selects the tokens by part of speech, none if nil
+	pos *PosFilter
 }
 
 // Tokenize cuts input into bleve token stream.
 func (seg *SegoTokenizer) Tokenize(input []byte) analysis.TokenStream {
-	segments := seg.seg.Segment(input)
-	ret := sego.SegmentsToTokenStream(input, segments, seg.searchMode)
+	ret, tags := seg.TokenizeTagged(input)
+	if seg.pos != nil {
+		ret = seg.pos.Filter(ret, tags)
+	}
 	return ret
 }
 
-func SegoTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
-	var seg sego.Segmenter
-
-	dictFilePath, ok := config["dict"].(string)
-	if ok {
-		err := seg.LoadDictionary(dictFilePath)
-		if err != nil {
-			return nil, err
-		}
+// TokenizeTagged cuts input into bleve token stream, along with the part of
+// speech tag of each token.
+func (seg *SegoTokenizer) TokenizeTagged(input []byte) (analysis.TokenStream, []string) {
+	var segments []sego.Segment
+	if seg.hmm {
+		segments = seg.dict.get().SegmentHMM(input)
 	} else {
-		file, err := dicts.Open(defaultDict)
-		if err != nil {
-			return nil, err
+		segments = seg.dict.get().Segment(input)
+	}
+	return sego.SegmentsToTaggedTokenStream(input, segments, seg.searchMode)
+}
+
+// dictFiles returns the dictionary files of a tokenizer config, given as a
+// comma separated string or as a list
+func dictFiles(config map[string]interface{}) []string {
+	files := make([]string, 0)
+	switch dict := config["dict"].(type) {
+	case string:
+		for _, file := range strings.Split(dict, ",") {
+			if file = strings.TrimSpace(file); file != "" {
+				files = append(files, file)
+			}
 		}
-		defer file.Close()
-		err = seg.LoadDictionaryFromReaders([]io.Reader{bufio.NewReader(file)})
-		if err != nil {
-			return nil, err
+	case []string:
+		files = append(files, dict...)
+	case []interface{}:
+		for _, file := range dict {
+			if file, ok := file.(string); ok && file != "" {
+				files = append(files, file)
+			}
 		}
 	}
+	return files
+}
+
+// SegoTokenizerConstructor builds a sego tokenizer. The "dict" files replace the
+// embedded default dictionary, unless "default" is true in which case they come
+// first, their words taking priority. Without "dict", the default dictionary is
+// used. Tokenizers using the same dictionaries share them.
+// With "hmm", runs of single characters are joined into the words missing from
+// the dictionaries, e.g. names, by a hidden Markov model. The tokens can be
+// selected by part of speech with "pos_drop" and "pos_keep" (see PosFilter).
+func SegoTokenizerConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.Tokenizer, error) {
+	files := dictFiles(config)
+	useDefault, ok := config["default"].(bool)
+	if !ok {
+		useDefault = len(files) == 0
+	}
+	dict, err := getSegoDictionary(files, useDefault)
+	if err != nil {
+		return nil, err
+	}
 
 	searchMode, ok := config["search"].(bool)
 	if !ok {
 		searchMode = true
 	}
 
+	hmm, _ := config["hmm"].(bool)
+
 	return &SegoTokenizer{
-		seg:        seg,
+		dict:       dict,
 		searchMode: searchMode,
+		hmm:        hmm,
+		pos:        posFilter(config),
 	}, nil
 }
 
-func AddSegoChineseAnalyzer(indexMapping *mapping.IndexMappingImpl) error {
-	err := indexMapping.AddCustomTokenizer("sego",
-		map[string]interface{}{
-			//"dict": "",
-			"search": true,
-			"type":   "sego",
-		})
-	if err != nil {
-		return err
+// SegoOptions configures the sego analyzer
+type SegoOptions struct {
+	// Dicts are user dictionaries, whose words are preferred to those of the default one
+	Dicts []string
+	// HMM recognizes the words missing from the dictionaries
+	HMM bool
+	// Convert is t2s or s2t to convert the terms between Traditional and
+	// Simplified Chinese, none if empty
+	Convert string
+	// PosDrop and PosKeep select the tokens by part of speech (see PosFilter)
+	PosDrop []string
+	PosKeep []string
+}
+
+// AddSegoChineseAnalyzer registers the sego tokenizer and analyzer
+func AddSegoChineseAnalyzer(indexMapping *mapping.IndexMappingImpl, options SegoOptions) error {
+	for name, searchMode := range map[string]bool{"sego": true, SegoQueryName: false} {
+		err := indexMapping.AddCustomTokenizer(name,
+			map[string]interface{}{
+				"dict":     stringsToInterfaces(options.Dicts),
+				"default":  true,
+				"search":   searchMode,
+				"hmm":      options.HMM,
+				"pos_drop": stringsToInterfaces(options.PosDrop),
+				"pos_keep": stringsToInterfaces(options.PosKeep),
+				"type":     "sego",
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	tokenFilters := []string{
+		"possessive_en",
+		"to_lower",
+	}
+	if options.Convert != "" {
+		err := indexMapping.AddCustomTokenFilter("sego_convert",
+			map[string]interface{}{
+				"type":      JianfanName,
+				"direction": options.Convert,
+			})
+		if err != nil {
+			return err
+		}
+		tokenFilters = append(tokenFilters, "sego_convert")
+	}
+	tokenFilters = append(tokenFilters, "stop_en", StopZhName)
+
+	for _, name := range []string{"sego", SegoQueryName} {
+		err := indexMapping.AddCustomAnalyzer(name,
+			map[string]interface{}{
+				"type":          custom.Name,
+				"tokenizer":     name,
+				"token_filters": tokenFilters,
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// segoQuery makes the match and phrase queries of q on the fields analyzed by
+// sego use the sego_query analyzer. Its plain segmentation of the query into
+// the longest words matches the index, which holds these words along with
+// their sub-words, whereas the search mode segmentation of the sego analyzer
+// would also require the sub-words of the query. q is returned unchanged when
+// the index mapping predates the sego_query analyzer.
+func segoQuery(q query.Query, indexMapping mapping.IndexMapping) query.Query {
+	impl, ok := indexMapping.(*mapping.IndexMappingImpl)
+	if !ok || impl.CustomAnalysis == nil || impl.CustomAnalysis.Analyzers[SegoQueryName] == nil {
+		return q
+	}
+	analyzer := func(field string) string {
+		if field == "" {
+			field = indexMapping.DefaultSearchField()
+		}
+		if indexMapping.AnalyzerNameForPath(field) == "sego" {
+			return SegoQueryName
+		}
+		return ""
 	}
 
-	err = indexMapping.AddCustomAnalyzer("sego",
-		map[string]interface{}{
-			"type":      custom.Name,
-			"tokenizer": "sego",
-			"token_filters": []string{
-				"possessive_en",
-				"to_lower",
-				"stop_en",
-			},
-		})
-	return err
+	switch q := q.(type) {
+	case *query.QueryStringQuery:
+		parsed, err := q.Parse()
+		if err != nil {
+			// let the search report the syntax error
+			return q
+		}
+		return segoQuery(parsed, indexMapping)
+	case *query.MatchQuery:
+		if q.Analyzer == "" {
+			q.Analyzer = analyzer(q.FieldVal)
+		}
+	case *query.MatchPhraseQuery:
+		if q.Analyzer == "" {
+			q.Analyzer = analyzer(q.FieldVal)
+		}
+	case *query.BooleanQuery:
+		if q.Must != nil {
+			q.Must = segoQuery(q.Must, indexMapping)
+		}
+		if q.Should != nil {
+			q.Should = segoQuery(q.Should, indexMapping)
+		}
+		if q.MustNot != nil {
+			q.MustNot = segoQuery(q.MustNot, indexMapping)
+		}
+	case *query.ConjunctionQuery:
+		for n, conjunct := range q.Conjuncts {
+			q.Conjuncts[n] = segoQuery(conjunct, indexMapping)
+		}
+	case *query.DisjunctionQuery:
+		for n, disjunct := range q.Disjuncts {
+			q.Disjuncts[n] = segoQuery(disjunct, indexMapping)
+		}
+	}
+	return q
+}
+
+// stringsToInterfaces converts a list for the analysis configs, which are
+// stored as JSON along with the index mapping
+func stringsToInterfaces(list []string) []interface{} {
+	values := make([]interface{}, len(list))
+	for n, s := range list {
+		values[n] = s
+	}
+	return values
 }
 
 func init() {
@@ -0,0 +1,190 @@
+package bleve
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+// tokenTerms returns the terms of the tokens of text cut by the sego tokenizer
+// built from config
+func tokenTerms(t *testing.T, config map[string]interface{}, text string) []string {
+	tokenizer, err := SegoTokenizerConstructor(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var terms []string
+	for _, token := range tokenizer.Tokenize([]byte(text)) {
+		terms = append(terms, string(token.Term))
+	}
+	return terms
+}
+
+func TestSegoTokenizerDictionaries(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "user.txt")
+	if err := os.WriteFile(file, []byte("caddy搜索 1000 n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   []string
+	}{
+		{"default", map[string]interface{}{"search": false}, []string{"北京", "caddy", "搜索"}},
+		// a configured dictionary replaces the default one
+		{"dict", map[string]interface{}{"dict": file, "search": false}, []string{"北", "京", "caddy搜索"}},
+		{"dict list", map[string]interface{}{"dict": []interface{}{file}, "search": false}, []string{"北", "京", "caddy搜索"}},
+		{"dict and default", map[string]interface{}{"dict": file, "default": true, "search": false}, []string{"北京", "caddy搜索"}},
+	}
+	for _, test := range tests {
+		if got := tokenTerms(t, test.config, "北京caddy搜索"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// the sego_dict files come along with the default dictionary
+	indexMap, err := indexMapping(indexer.Config{Analyzer: "sego", SegoDicts: []string{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := indexMap.AnalyzeText(SegoQueryName, []byte("北京caddy搜索"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var terms []string
+	for _, token := range tokens {
+		terms = append(terms, string(token.Term))
+	}
+	if want := []string{"北京", "caddy搜索"}; !reflect.DeepEqual(terms, want) {
+		t.Errorf("sego_dict: got %v, want %v", terms, want)
+	}
+
+	if _, err := SegoTokenizerConstructor(map[string]interface{}{"dict": file + ".missing"}, nil); err == nil {
+		t.Error("got no error for a missing dictionary")
+	}
+}
+
+func TestDictFiles(t *testing.T) {
+	tests := []struct {
+		dict interface{}
+		want []string
+	}{
+		{nil, []string{}},
+		{"a.txt, b.txt,", []string{"a.txt", "b.txt"}},
+		{[]string{"a.txt"}, []string{"a.txt"}},
+		{[]interface{}{"a.txt", "", 3, "b.txt"}, []string{"a.txt", "b.txt"}},
+	}
+	for _, test := range tests {
+		if got := dictFiles(map[string]interface{}{"dict": test.dict}); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("dictFiles(%v) = %v, want %v", test.dict, got, test.want)
+		}
+	}
+}
+
+// matchAnalyzers returns the analyzers of the match queries of q, keyed by
+// their field and text
+func matchAnalyzers(q query.Query, analyzers map[string]string) map[string]string {
+	switch q := q.(type) {
+	case *query.MatchQuery:
+		analyzers[q.FieldVal+":"+q.Match] = q.Analyzer
+	case *query.MatchPhraseQuery:
+		analyzers[q.FieldVal+":"+q.MatchPhrase] = q.Analyzer
+	case *query.BooleanQuery:
+		for _, sub := range []query.Query{q.Must, q.Should, q.MustNot} {
+			if sub != nil {
+				matchAnalyzers(sub, analyzers)
+			}
+		}
+	case *query.ConjunctionQuery:
+		for _, conjunct := range q.Conjuncts {
+			matchAnalyzers(conjunct, analyzers)
+		}
+	case *query.DisjunctionQuery:
+		for _, disjunct := range q.Disjuncts {
+			matchAnalyzers(disjunct, analyzers)
+		}
+	}
+	return analyzers
+}
+
+func TestSegoQuery(t *testing.T) {
+	indexMap, err := indexMapping(indexer.Config{Analyzer: "sego", FieldAnalyzers: map[string]string{"Path": "standard"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := segoQuery(bleve.NewQueryStringQuery(`北京 Title:上海 +搜索引擎 -Path:caddy "中华人民共和国"`), indexMap)
+	if _, ok := q.(*query.QueryStringQuery); ok {
+		t.Fatal("got the query string query unchanged")
+	}
+	want := map[string]string{
+		":北京":        SegoQueryName,
+		"Title:上海":   SegoQueryName,
+		":搜索引擎":      SegoQueryName,
+		"Path:caddy": "",
+		":中华人民共和国":   SegoQueryName,
+	}
+	if got := matchAnalyzers(q, make(map[string]string)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// a match query with an analyzer keeps it
+	match := bleve.NewMatchQuery("北京")
+	match.Analyzer = "standard"
+	if got := segoQuery(match, indexMap).(*query.MatchQuery).Analyzer; got != "standard" {
+		t.Errorf("got analyzer %q, want standard", got)
+	}
+
+	// a syntax error is left to the search
+	invalid := bleve.NewQueryStringQuery(`Title:"北京`)
+	if got := segoQuery(invalid, indexMap); got != query.Query(invalid) {
+		t.Errorf("got %v for an invalid query, want it unchanged", got)
+	}
+
+	// mappings predating sego_query are left alone
+	for _, old := range []mapping.IndexMapping{bleve.NewIndexMapping(), mapping.NewIndexMapping()} {
+		q := bleve.NewQueryStringQuery("北京")
+		if got := segoQuery(q, old); got != query.Query(q) {
+			t.Errorf("got %v for a mapping without %v, want the query unchanged", got, SegoQueryName)
+		}
+	}
+}
+
+func TestSearchSego(t *testing.T) {
+	i := newTestIndexer(t, indexer.Config{Analyzer: "sego"})
+	indexTestRecord(i, "/1.md", "我爱中华人民共和国", time.Now())
+	indexTestRecord(i, "/2.md", "人民的搜索引擎", time.Now())
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"中华人民共和国", []string{"/1.md"}},
+		{"+中华 +共和国", []string{"/1.md"}},
+		{"人民", []string{"/1.md", "/2.md"}},
+		{"搜索引擎", []string{"/2.md"}},
+		{"上海", nil},
+	}
+	for _, test := range tests {
+		resp := i.Search(indexer.SearchRequest{Query: test.query, Size: 10})
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		var got []string
+		for _, hit := range resp.Hits {
+			got = append(got, hit.Path())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.query, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package bleve
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/tokenmap"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+
+	// the components usable by name in the custom analyzers and token filters
+	_ "github.com/blevesearch/bleve/v2/analysis/char/html"
+	_ "github.com/blevesearch/bleve/v2/analysis/char/regexp"
+	_ "github.com/blevesearch/bleve/v2/analysis/char/zerowidthnonjoiner"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/apostrophe"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/camelcase"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/compound"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/edgengram"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/elision"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/keyword"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/length"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/ngram"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/porter"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/reverse"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/shingle"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/stop"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/truncate"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/unicodenorm"
+	_ "github.com/blevesearch/bleve/v2/analysis/token/unique"
+	_ "github.com/blevesearch/bleve/v2/analysis/tokenizer/exception"
+	_ "github.com/blevesearch/bleve/v2/analysis/tokenizer/regexp"
+	_ "github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	_ "github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	_ "github.com/blevesearch/bleve/v2/analysis/tokenizer/web"
+	_ "github.com/blevesearch/bleve/v2/analysis/tokenizer/whitespace"
+)
+
+// textFields are the fields whose analyzer can be set, besides Bodies.<lang>
+var textFields = []string{"Path", "Title", "Body", "Description", "Keywords", "Headings", "Author"}
+
+// isTextField reports if field is one of the text fields of the records
+func isTextField(field string, languages []string) bool {
+	for _, f := range textFields {
+		if f == field {
+			return true
+		}
+	}
+	for _, lang := range languages {
+		if field == "Bodies."+lang {
+			return true
+		}
+	}
+	return false
+}
+
+// usesSego reports if the configuration refers to the sego tokenizer or analyzer
+func usesSego(config indexer.Config) bool {
+	if config.Analyzer == "sego" || config.Pinyin {
+		return true
+	}
+	for _, lang := range config.Languages {
+		if languageAnalyzers[lang] == "sego" {
+			return true
+		}
+	}
+	for _, analyzer := range config.FieldAnalyzers {
+		if analyzer == "sego" {
+			return true
+		}
+	}
+	for _, analyzer := range config.CustomAnalyzers {
+		if analyzer.Tokenizer == "sego" {
+			return true
+		}
+	}
+	return false
+}
+
+// addCustomAnalysis registers the token maps, token filters and analyzers
+// defined by the configuration
+func addCustomAnalysis(indexMap *mapping.IndexMappingImpl, config indexer.Config) error {
+	for name, words := range config.TokenMaps {
+		tokens := make([]interface{}, len(words))
+		for n, word := range words {
+			tokens[n] = word
+		}
+		err := indexMap.AddCustomTokenMap(name, map[string]interface{}{
+			"type":   tokenmap.Name,
+			"tokens": tokens,
+		})
+		if err != nil {
+			return fmt.Errorf("token map %v: %v", name, err)
+		}
+	}
+
+	for name, filter := range config.TokenFilters {
+		options := map[string]interface{}{"type": filter.Type}
+		for key, value := range filter.Options {
+			options[key] = value
+		}
+		if err := indexMap.AddCustomTokenFilter(name, options); err != nil {
+			return fmt.Errorf("token filter %v: %v", name, err)
+		}
+	}
+
+	for name, analyzer := range config.CustomAnalyzers {
+		charFilters := make([]interface{}, len(analyzer.CharFilters))
+		for n, charFilter := range analyzer.CharFilters {
+			charFilters[n] = charFilter
+		}
+		tokenFilters := make([]interface{}, len(analyzer.TokenFilters))
+		for n, tokenFilter := range analyzer.TokenFilters {
+			tokenFilters[n] = tokenFilter
+		}
+		err := indexMap.AddCustomAnalyzer(name, map[string]interface{}{
+			"type":          custom.Name,
+			"tokenizer":     analyzer.Tokenizer,
+			"char_filters":  charFilters,
+			"token_filters": tokenFilters,
+		})
+		if err != nil {
+			return fmt.Errorf("analyzer %v: %v", name, err)
+		}
+	}
+	return nil
+}
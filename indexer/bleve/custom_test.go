@@ -0,0 +1,92 @@
+package bleve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+func TestCustomAnalysis(t *testing.T) {
+	config := indexer.Config{
+		CustomAnalyzers: map[string]indexer.CustomAnalyzer{
+			"mine":   {Tokenizer: "whitespace", CharFilters: []string{"html"}, TokenFilters: []string{"to_lower", "my_stop", "my_length"}},
+			"french": {Tokenizer: "unicode", TokenFilters: []string{"to_lower", "elision_fr", "my_stop", "stemmer_fr_light"}},
+		},
+		TokenFilters: map[string]indexer.Component{
+			"my_stop":   {Type: "stop_tokens", Options: map[string]interface{}{"stop_token_map": "my_words"}},
+			"my_length": {Type: "length", Options: map[string]interface{}{"min": 2.0}},
+		},
+		TokenMaps:      map[string][]string{"my_words": {"the", "an", "caddy"}},
+		FieldAnalyzers: map[string]string{"Title": "mine"},
+	}
+	indexMap, err := indexMapping(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		analyzer, text string
+		want           []string
+	}{
+		{"mine", "<b>The</b> Caddy web-server, a server", []string{"web-server,", "server"}},
+		{"french", "L'équipe de Caddy utilise les serveurs", []string{"equip", "de", "utilis", "les", "serveu"}},
+	}
+	for _, test := range tests {
+		tokens, err := indexMap.AnalyzeText(test.analyzer, []byte(test.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var terms []string
+		for _, token := range tokens {
+			terms = append(terms, string(token.Term))
+		}
+		if !reflect.DeepEqual(terms, test.want) {
+			t.Errorf("%v: got %v, want %v", test.analyzer, terms, test.want)
+		}
+	}
+	if got := indexMap.FieldAnalyzer("Title"); got != "mine" {
+		t.Errorf("got Title analyzer %q, want mine", got)
+	}
+}
+
+func TestCustomAnalysisErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config indexer.Config
+	}{
+		{"unknown field", indexer.Config{FieldAnalyzers: map[string]string{"Missing": "standard"}}},
+		{"unknown language field", indexer.Config{Languages: []string{"en"}, FieldAnalyzers: map[string]string{"Bodies.fr": "fr"}}},
+		{"unknown filter type", indexer.Config{TokenFilters: map[string]indexer.Component{"my": {Type: "missing"}}}},
+		{"unknown tokenizer", indexer.Config{CustomAnalyzers: map[string]indexer.CustomAnalyzer{"my": {Tokenizer: "missing"}}}},
+		{"unknown token filter", indexer.Config{CustomAnalyzers: map[string]indexer.CustomAnalyzer{
+			"my": {Tokenizer: "unicode", TokenFilters: []string{"missing"}},
+		}}},
+	}
+	for _, test := range tests {
+		if _, err := indexMapping(test.config); err == nil {
+			t.Errorf("%v: got no error", test.name)
+		}
+	}
+}
+
+func TestUsesSego(t *testing.T) {
+	tests := []struct {
+		name   string
+		config indexer.Config
+		want   bool
+	}{
+		{"none", indexer.Config{Analyzer: "standard"}, false},
+		{"analyzer", indexer.Config{Analyzer: "sego"}, true},
+		{"pinyin", indexer.Config{Pinyin: true}, true},
+		{"language", indexer.Config{Languages: []string{"zh"}}, true},
+		{"other language", indexer.Config{Languages: []string{"en"}}, false},
+		{"field", indexer.Config{FieldAnalyzers: map[string]string{"Title": "sego"}}, true},
+		{"custom analyzer", indexer.Config{CustomAnalyzers: map[string]indexer.CustomAnalyzer{"my": {Tokenizer: "sego"}}}, true},
+	}
+	for _, test := range tests {
+		if got := usesSego(test.config); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -1,15 +1,38 @@
 package bleve
 
 import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	bleve "github.com/blevesearch/bleve/v2"
-	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/html"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/highlight"
+	simpleFragmenter "github.com/blevesearch/bleve/v2/search/highlight/fragmenter/simple"
+	simpleHighlighter "github.com/blevesearch/bleve/v2/search/highlight/highlighter/simple"
+	"github.com/blevesearch/bleve/v2/search/query"
+	index "github.com/blevesearch/bleve_index_api"
 	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
 )
 
 type bleveIndexer struct {
-	bleve bleve.Index
+	bleve     bleve.Index
+	languages []string
+	// segoDicts are the user dictionaries of the sego tokenizer
+	segoDicts []string
+	// pinyin matches Latin-only queries with the pinyin of the titles and bodies
+	pinyin bool
+	// sego analyzes the queries on the fields analyzed by sego with sego_query
+	sego bool
 }
 
 // Bleve's record data struct
@@ -19,6 +42,64 @@ type indexRecord struct {
 	Body     string
 	Modified time.Time
 	Indexed  time.Time
+	// FullPath is the source file of file backed records
+	FullPath   string
+	FileBacked bool
+	// MimeType and Section are keywords, used to facet and filter hits
+	MimeType string
+	Section  string
+	// metadata extracted from the documents, OpenGraph holds the og:* properties
+	Description string
+	Keywords    string
+	Lang        string
+	Canonical   string
+	Headings    string
+	Author      string
+	OpenGraph   map[string]string
+	// Bodies holds the body again under its language, for the configured languages
+	Bodies map[string]string
+}
+
+// metaFields maps the record metadata keys to the indexRecord fields holding them
+var metaFields = map[string]string{
+	"description": "Description",
+	"keywords":    "Keywords",
+	"lang":        "Lang",
+	"canonical":   "Canonical",
+	"headings":    "Headings",
+	"author":      "Author",
+}
+
+// openGraph returns the og:* metadata of rec, keyed without the og: prefix
+func openGraph(rec *Record) map[string]string {
+	var og map[string]string
+	for key, value := range rec.meta {
+		if strings.HasPrefix(key, "og:") {
+			if og == nil {
+				og = make(map[string]string)
+			}
+			og[strings.TrimPrefix(key, "og:")] = value
+		}
+	}
+	return og
+}
+
+// Type makes bleve index the records with the "document" mapping
+func (r indexRecord) Type() string {
+	return "document"
+}
+
+// section returns the top level directory of path, "/" for files at the root
+func section(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if n := strings.IndexAny(path, "?#"); n >= 0 {
+		path = path[:n]
+	}
+	n := strings.Index(path, "/")
+	if n <= 0 {
+		return "/"
+	}
+	return path[:n]
 }
 
 // Record method get existent or creates a new Record to be saved/updated in the indexer
@@ -35,37 +116,311 @@ func (i *bleveIndexer) Record(path string) indexer.Record {
 	record.modified = time.Time{}
 	record.indexer = i
 	record.mimetype = ""
+	record.meta = make(map[string]string)
 	return record
 }
 
 // Search method lookup for records using a query
-func (i *bleveIndexer) Search(q string, from, size int) (records []indexer.Record) {
-	query := bleve.NewQueryStringQuery(q)
-	request := bleve.NewSearchRequest(query)
-	request.Highlight = bleve.NewHighlightWithStyle(html.Name) //bleve.NewHighlight()
-	request.From = from
-	request.Size = size
+func (i *bleveIndexer) Search(req indexer.SearchRequest) (resp indexer.SearchResponse) {
+	queryString := bleve.NewQueryStringQuery(req.Query)
+	if _, err := queryString.Parse(); err != nil {
+		resp.Err = &indexer.QueryError{Err: err}
+		return
+	}
+	var q query.Query = queryString
+	if len(i.languages) > 0 {
+		q = languagesQuery(q, req.Query, i.languages)
+	}
+	if i.pinyin {
+		q = pinyinQuery(q, req.Query)
+	}
+	if len(req.Boosts) > 0 {
+		q = boostQuery(q, req.Query, req.Boosts)
+	}
+	if i.sego {
+		q = segoQuery(q, i.bleve.Mapping())
+	}
+	unfiltered := q
+	if len(req.Filters) > 0 {
+		conjuncts := []query.Query{q}
+		for _, filter := range req.Filters {
+			conjuncts = append(conjuncts, filterQuery(filter))
+		}
+		q = bleve.NewConjunctionQuery(conjuncts...)
+	}
+
+	request := bleve.NewSearchRequestOptions(q, req.Size, req.From, false)
+	if req.Highlight != nil {
+		request.IncludeLocations = true
+	}
+	if len(req.Sort) > 0 {
+		request.SortBy(req.Sort)
+	}
+	for name, facet := range req.Facets {
+		// bleve keeps the first size date ranges only
+		size := facet.Size
+		if size < len(facet.DateRanges) {
+			size = len(facet.DateRanges)
+		}
+		facetRequest := bleve.NewFacetRequest(facet.Field, size)
+		for _, dr := range facet.DateRanges {
+			facetRequest.AddDateTimeRange(dr.Name, dr.Start, dr.End)
+		}
+		request.AddFacet(name, facetRequest)
+	}
+
 	result, err := i.bleve.Search(request)
-	if err != nil { // an empty query would cause this
+	if err != nil {
+		resp.Err = err
 		return
 	}
+	resp.Total = result.Total
+	resp.Took = result.Took
+	resp.Facets = facets(req.Facets, result.Facets)
+	// a query matching documents outside of the filters is spelled right
+	if result.Total == 0 && (len(req.Filters) == 0 || i.count(unfiltered) == 0) {
+		resp.Suggestion, _ = i.correct(req.Query)
+	}
+
+	var hl *highlighter
+	if req.Highlight != nil {
+		hl = newHighlighter(*req.Highlight)
+	}
 
 	for _, match := range result.Hits {
-		rec := i.Record(match.ID)
-		loaded := rec.Load()
+		doc, err := i.bleve.Document(match.ID)
+		if err != nil || doc == nil {
+			continue
+		}
+		rec := i.Record(match.ID).(*Record)
+		rec.load(doc)
+
+		hit := indexer.Hit{
+			Record: rec,
+			Score:  match.Score,
+		}
+		if hl != nil {
+			hit.Fragments = hl.fragments(match, doc)
+		}
+		resp.Hits = append(resp.Hits, hit)
+	}
 
-		if !loaded {
+	return
+}
+
+// count returns the number of documents matched by q, 0 if the search fails
+func (i *bleveIndexer) count(q query.Query) uint64 {
+	result, err := i.bleve.Search(bleve.NewSearchRequestOptions(q, 0, 0, false))
+	if err != nil {
+		return 0
+	}
+	return result.Total
+}
+
+// highlighter marks the matched terms of the hits
+type highlighter struct {
+	fields []string
+	num    int
+	// text highlights fragments of the fields, title the whole titles
+	text  highlight.Highlighter
+	title highlight.Highlighter
+}
+
+// newHighlighter creates a highlighter, zero options fall back to bleve's defaults
+func newHighlighter(opts indexer.HighlightOptions) *highlighter {
+	if opts.Fragments <= 0 {
+		opts.Fragments = 1
+	}
+	if opts.FragmentSize <= 0 {
+		opts.FragmentSize = defaultFragmentSize
+	}
+	if opts.Before == "" && opts.After == "" {
+		opts.Before, opts.After = "<mark>", "</mark>"
+	}
+	formatter := &escapingFormatter{before: opts.Before, after: opts.After}
+	return &highlighter{
+		fields: opts.Fields,
+		num:    opts.Fragments,
+		text: simpleHighlighter.NewHighlighter(simpleFragmenter.NewFragmenter(opts.FragmentSize),
+			formatter, simpleHighlighter.DefaultSeparator),
+		title: simpleHighlighter.NewHighlighter(simpleFragmenter.NewFragmenter(maxTitleSize),
+			formatter, simpleHighlighter.DefaultSeparator),
+	}
+}
+
+// escapingFormatter formats the fragments of the fields, all stored as plain
+// text: it marks the matched terms with before and after, and unlike bleve's
+// html formatter escapes the text around them
+type escapingFormatter struct {
+	before string
+	after  string
+}
+
+// Format returns the HTML of fragment, marking the terms at locations
+func (f *escapingFormatter) Format(fragment *highlight.Fragment, locations highlight.TermLocations) string {
+	var b strings.Builder
+	curr := fragment.Start
+	for _, location := range locations {
+		if location == nil || !location.ArrayPositions.Equals(fragment.ArrayPositions) || location.Start < curr {
 			continue
 		}
+		if location.End > fragment.End {
+			break
+		}
+		b.WriteString(html.EscapeString(string(fragment.Orig[curr:location.Start])))
+		b.WriteString(f.before)
+		b.WriteString(html.EscapeString(string(fragment.Orig[location.Start:location.End])))
+		b.WriteString(f.after)
+		curr = location.End
+	}
+	b.WriteString(html.EscapeString(string(fragment.Orig[curr:fragment.End])))
+	return b.String()
+}
+
+const (
+	// defaultFragmentSize is the fragment size of bleve's simple fragmenter
+	defaultFragmentSize = 200
+	// maxTitleSize is the fragment size used to highlight whole titles
+	maxTitleSize = 1024
+)
 
-		if len(match.Fragments["Body"]) > 0 {
-			rec.SetBody([]byte(match.Fragments["Body"][0]))
+// fragments returns the highlighted fragments of the fields of doc holding a match
+func (hl *highlighter) fragments(match *search.DocumentMatch, doc index.Document) map[string][]string {
+	fields := hl.fields
+	if len(fields) == 0 {
+		for field := range match.Locations {
+			fields = append(fields, field)
 		}
+	}
 
-		records = append(records, rec)
+	fragments := make(map[string][]string)
+	for _, field := range fields {
+		if len(match.Locations[field]) == 0 {
+			continue
+		}
+		var best []string
+		if field == "Title" {
+			best = hl.title.BestFragmentsInField(match, doc, field, 1)
+		} else {
+			best = hl.text.BestFragmentsInField(match, doc, field, hl.num)
+		}
+		if len(best) > 0 {
+			fragments[field] = best
+		}
 	}
+	return fragments
+}
 
-	return
+// facets converts bleve's facet results to the indexer ones
+func facets(requests map[string]indexer.FacetRequest, results search.FacetResults) map[string]indexer.Facet {
+	if len(results) == 0 {
+		return nil
+	}
+	ret := make(map[string]indexer.Facet, len(results))
+	for name, result := range results {
+		facet := indexer.Facet{
+			Field:   result.Field,
+			Total:   result.Total,
+			Missing: result.Missing,
+			Other:   result.Other,
+		}
+		for _, term := range result.Terms {
+			facet.Terms = append(facet.Terms, indexer.FacetTerm{
+				Term:  term.Term,
+				Count: term.Count,
+			})
+		}
+		// bleve returns the ranges sorted by count, keep the requested order
+		for _, dr := range requests[name].DateRanges {
+			count := 0
+			for _, r := range result.DateRanges {
+				if r.Name == dr.Name {
+					count = r.Count
+					break
+				}
+			}
+			facet.DateRanges = append(facet.DateRanges, indexer.FacetDateRange{
+				DateRange: dr,
+				Count:     count,
+			})
+		}
+		ret[name] = facet
+	}
+	return ret
+}
+
+// boostQuery adds to the score of the documents matched by q the scores of
+// the words of the query in each boosted field, times the boost of the field
+func boostQuery(q query.Query, queryString string, boosts map[string]float64) query.Query {
+	text := queryText(queryString)
+	if text == "" {
+		return q
+	}
+	fields := make([]string, 0, len(boosts))
+	for field := range boosts {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	boosted := bleve.NewBooleanQuery()
+	boosted.AddMust(q)
+	for _, field := range fields {
+		match := bleve.NewMatchQuery(text)
+		match.SetField(field)
+		match.SetBoost(boosts[field])
+		boosted.AddShould(match)
+	}
+	return boosted
+}
+
+// queryText returns the words of a query string, without its syntax and excluded words
+func queryText(queryString string) string {
+	words := make([]string, 0)
+	for _, word := range strings.Fields(queryString) {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		word = strings.TrimPrefix(word, "+")
+		if n := strings.Index(word, ":"); n >= 0 {
+			word = word[n+1:]
+		}
+		if n := strings.IndexAny(word, "~^"); n >= 0 {
+			word = word[:n]
+		}
+		word = strings.Trim(word, `"()*?`)
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// filterQuery matches the documents satisfying all the criteria of filter
+func filterQuery(filter indexer.Filter) query.Query {
+	conjuncts := make([]query.Query, 0)
+	if len(filter.Values) > 0 {
+		terms := make([]query.Query, len(filter.Values))
+		for n, value := range filter.Values {
+			term := bleve.NewTermQuery(value)
+			term.SetField(filter.Field)
+			terms[n] = term
+		}
+		conjuncts = append(conjuncts, bleve.NewDisjunctionQuery(terms...))
+	}
+	if filter.Prefix != "" {
+		prefix := bleve.NewPrefixQuery(filter.Prefix)
+		prefix.SetField(filter.Field)
+		conjuncts = append(conjuncts, prefix)
+	}
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		dateRange := bleve.NewDateRangeQuery(filter.Start, filter.End)
+		dateRange.SetField(filter.Field)
+		conjuncts = append(conjuncts, dateRange)
+	}
+	if len(conjuncts) == 1 {
+		return conjuncts[0]
+	}
+	return bleve.NewConjunctionQuery(conjuncts...)
 }
 
 // Index sends the new record to the pipeline
@@ -84,11 +439,24 @@ func (i *bleveIndexer) index(rec *Record) {
 		//fmt.Println(rec.FullPath())
 
 		r := indexRecord{
-			Path:     rec.Path(),
-			Title:    rec.Title(),
-			Body:     string(rec.body),
-			Modified: rec.Modified(),
-			Indexed:  rec.Indexed(),
+			Path:       rec.Path(),
+			Title:      rec.Title(),
+			Body:       string(rec.body),
+			Modified:   rec.Modified(),
+			Indexed:    rec.Indexed(),
+			FullPath:   rec.FullPath(),
+			FileBacked: rec.FullPath() != "",
+			MimeType:   strings.TrimSpace(strings.Split(rec.MimeType(), ";")[0]),
+			Section:    section(rec.Path()),
+
+			Description: rec.Meta("description"),
+			Keywords:    rec.Meta("keywords"),
+			Lang:        rec.Meta("lang"),
+			Canonical:   rec.Meta("canonical"),
+			Headings:    rec.Meta("headings"),
+			Author:      rec.Meta("author"),
+			OpenGraph:   openGraph(rec),
+			Bodies:      bodies(rec, i.languages),
 		}
 
 		//t := time.Now()
@@ -97,45 +465,228 @@ func (i *bleveIndexer) index(rec *Record) {
 	}
 }
 
+// Delete removes the record stored under path from the index, along with the
+// records of its parts (path#part)
+func (i *bleveIndexer) Delete(path string) error {
+	if err := i.bleve.Delete(path); err != nil {
+		return err
+	}
+	return i.DeletePrefix(path + "#")
+}
+
+// DeletePrefix removes the records whose path starts with prefix, e.g. those
+// under a directory
+func (i *bleveIndexer) DeletePrefix(prefix string) error {
+	query := bleve.NewPrefixQuery(prefix)
+	query.SetField("PathKeyword")
+	for {
+		request := bleve.NewSearchRequestOptions(query, 1000, 0, false)
+		result, err := i.bleve.Search(request)
+		if err != nil {
+			return err
+		}
+		if len(result.Hits) == 0 {
+			return nil
+		}
+		batch := i.bleve.NewBatch()
+		for _, hit := range result.Hits {
+			batch.Delete(hit.ID)
+		}
+		if err := i.bleve.Batch(batch); err != nil {
+			return err
+		}
+	}
+}
+
+// FileBacked returns the full path of every record indexed from a file, keyed by record path
+func (i *bleveIndexer) FileBacked() (map[string]string, error) {
+	const pageSize = 1000
+	query := bleve.NewBoolFieldQuery(true)
+	query.SetField("FileBacked")
+
+	docs := make(map[string]string)
+	for from := 0; ; from += pageSize {
+		request := bleve.NewSearchRequestOptions(query, pageSize, from, false)
+		request.Fields = []string{"FullPath"}
+		request.SortBy([]string{"_id"})
+		result, err := i.bleve.Search(request)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range result.Hits {
+			fullPath, _ := match.Fields["FullPath"].(string)
+			docs[match.ID] = fullPath
+		}
+		if len(result.Hits) < pageSize {
+			break
+		}
+	}
+	return docs, nil
+}
+
 // New creates a new instance for this indexer
-func New(name string, analyzer string) (*bleveIndexer, error) {
-	blv, err := openIndex(name, analyzer)
+func New(name string, config indexer.Config) (*bleveIndexer, error) {
+	languages := make([]string, len(config.Languages))
+	for n, lang := range config.Languages {
+		languages[n] = primaryLanguage(lang)
+	}
+	config.Languages = languages
+	setSegoCacheDir(config.SegoCacheDir)
+	blv, err := openIndex(name, config)
 	if err != nil {
 		return nil, err
 	}
+	return newIndexer(blv, config), nil
+}
 
+// newIndexer wraps an open index, whose mapping was created from config
+func newIndexer(blv bleve.Index, config indexer.Config) *bleveIndexer {
 	indxr := &bleveIndexer{}
 	indxr.bleve = blv
+	indxr.languages = config.Languages
+	indxr.segoDicts = config.SegoDicts
+	indxr.pinyin = config.Pinyin
+	indxr.sego = usesSego(config)
+	return indxr
+}
+
+// mappingVersionKey is the internal key storing the version of the mapping of an index
+var mappingVersionKey = []byte("caddy-search:mapping")
+
+func openIndex(name string, config indexer.Config) (bleve.Index, error) {
+	indexMap, err := indexMapping(config)
+	if err != nil {
+		return nil, err
+	}
+	version, err := mappingVersion(indexMap)
+	if err != nil {
+		return nil, err
+	}
 
-	return indxr, nil
+	blv, err := bleve.Open(name)
+	if err == nil {
+		stored, err := blv.GetInternal(mappingVersionKey)
+		if err == nil && string(stored) == version {
+			return blv, nil
+		}
+		// the records of an index created by an older version or with other
+		// options lack fields (e.g. PathKeyword) or are analyzed differently.
+		// The index is created again, the scan of the site indexing it anew.
+		log.Printf("Rebuilding the index %s, whose mapping changed", name)
+		blv.Close()
+		if err := os.RemoveAll(name); err != nil {
+			return nil, err
+		}
+	} else if err != bleve.ErrorIndexPathDoesNotExist {
+		return nil, err
+	}
+
+	//blv, err := bleve.New(name, indexMap)
+	blv, err = bleve.NewUsing(name, indexMap, "scorch", "scorch", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := blv.SetInternal(mappingVersionKey, []byte(version)); err != nil {
+		blv.Close()
+		return nil, err
+	}
+	return blv, nil
+}
+
+// recordFormat changes along with the content of the stored fields, e.g. when
+// the bodies of HTML documents stopped being stored escaped
+const recordFormat = "plain-text-bodies"
+
+// mappingVersion identifies indexMap and the format of the records, the same
+// fields, analysis and format giving the same version
+func mappingVersion(indexMap *mapping.IndexMappingImpl) (string, error) {
+	data, err := json.Marshal(indexMap)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(append(data, recordFormat...))
+	return hex.EncodeToString(sum[:]), nil
 }
-func openIndex(name string, analyzer string) (bleve.Index, error) {
-	textFieldMapping := bleve.NewTextFieldMapping()
+
+// indexMapping creates the mapping of the records and the analysis components described by config
+func indexMapping(config indexer.Config) (*mapping.IndexMappingImpl, error) {
+	for field := range config.FieldAnalyzers {
+		if !isTextField(field, config.Languages) {
+			return nil, fmt.Errorf("no text field %q", field)
+		}
+	}
+	// textField maps a text field, with its own analyzer if configured
+	textField := func(field string) *mapping.FieldMapping {
+		fieldMapping := bleve.NewTextFieldMapping()
+		fieldMapping.Analyzer = config.FieldAnalyzers[field]
+		return fieldMapping
+	}
+
+	// the untokenized path, indexed as PathKeyword for prefix queries
+	pathKeywordMapping := bleve.NewTextFieldMapping()
+	pathKeywordMapping.Name = "PathKeyword"
+	pathKeywordMapping.Analyzer = keyword.Name
+	pathKeywordMapping.Store = false
+	pathKeywordMapping.IncludeInAll = false
 
 	doc := bleve.NewDocumentMapping()
-	doc.AddFieldMappingsAt("Path", textFieldMapping)
-	doc.AddFieldMappingsAt("Title", textFieldMapping)
-	doc.AddFieldMappingsAt("Body", textFieldMapping)
+	doc.AddFieldMappingsAt("Path", textField("Path"), pathKeywordMapping)
+	if config.Pinyin {
+		doc.AddFieldMappingsAt("Title", textField("Title"), pinyinMapping())
+		doc.AddFieldMappingsAt("Body", textField("Body"), pinyinMapping())
+	} else {
+		doc.AddFieldMappingsAt("Title", textField("Title"))
+		doc.AddFieldMappingsAt("Body", textField("Body"))
+	}
 	doc.AddFieldMappingsAt("Modified", bleve.NewDateTimeFieldMapping())
 	doc.AddFieldMappingsAt("Indexed", bleve.NewDateTimeFieldMapping())
 
-	indexMap := bleve.NewIndexMapping()
-	switch analyzer {
-	case "sego":
-		AddSegoChineseAnalyzer(indexMap)
-	}
-	indexMap.DefaultAnalyzer = analyzer
-	indexMap.AddDocumentMapping("document", doc)
+	storedFieldMapping := bleve.NewTextFieldMapping()
+	storedFieldMapping.Index = false
+	storedFieldMapping.IncludeInAll = false
+	doc.AddFieldMappingsAt("FullPath", storedFieldMapping)
+	doc.AddFieldMappingsAt("FileBacked", bleve.NewBooleanFieldMapping())
 
-	//blv, err := bleve.New(name, indexMap)
-	blv, err := bleve.NewUsing(name, indexMap, "scorch", "scorch", nil)
+	keywordFieldMapping := bleve.NewTextFieldMapping()
+	keywordFieldMapping.Analyzer = keyword.Name
+	keywordFieldMapping.IncludeInAll = false
+	doc.AddFieldMappingsAt("MimeType", keywordFieldMapping)
+	doc.AddFieldMappingsAt("Section", keywordFieldMapping)
+	doc.AddFieldMappingsAt("Lang", keywordFieldMapping)
+
+	doc.AddFieldMappingsAt("Description", textField("Description"))
+	doc.AddFieldMappingsAt("Keywords", textField("Keywords"))
+	doc.AddFieldMappingsAt("Headings", textField("Headings"))
+	doc.AddFieldMappingsAt("Author", textField("Author"))
+	doc.AddFieldMappingsAt("Canonical", storedFieldMapping)
 
+	bodies, err := bodiesMapping(config.Languages, config.FieldAnalyzers)
 	if err != nil {
-		blv, err = bleve.Open(name)
-		if err != nil {
+		return nil, err
+	}
+	doc.AddSubDocumentMapping("Bodies", bodies)
+
+	indexMap := bleve.NewIndexMapping()
+	if usesSego(config) {
+		if err := AddSegoChineseAnalyzer(indexMap, SegoOptions{
+			Dicts:   config.SegoDicts,
+			HMM:     config.SegoHMM,
+			Convert: config.SegoConvert,
+			PosDrop: config.SegoPosDrop,
+			PosKeep: config.SegoPosKeep,
+		}); err != nil {
 			return nil, err
 		}
 	}
-
-	return blv, nil
+	if config.Pinyin {
+		if err := addPinyinAnalyzer(indexMap); err != nil {
+			return nil, err
+		}
+	}
+	if err := addCustomAnalysis(indexMap, config); err != nil {
+		return nil, err
+	}
+	indexMap.DefaultAnalyzer = config.Analyzer
+	indexMap.AddDocumentMapping("document", doc)
+	return indexMap, nil
 }
@@ -0,0 +1,376 @@
+package bleve
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+// newTestIndexer creates an indexer over an in-memory index mapped from config
+func newTestIndexer(t *testing.T, config indexer.Config) *bleveIndexer {
+	if config.Analyzer == "" {
+		config.Analyzer = "standard"
+	}
+	indexMap, err := indexMapping(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blv, err := bleve.NewMemOnly(indexMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { blv.Close() })
+	return newIndexer(blv, config)
+}
+
+// newScorchTestIndexer creates an indexer over a scorch index in a temporary
+// directory, opened like the indexes of the sites
+func newScorchTestIndexer(t *testing.T, config indexer.Config) *bleveIndexer {
+	if config.Analyzer == "" {
+		config.Analyzer = "standard"
+	}
+	blv, err := openIndex(filepath.Join(t.TempDir(), "index"), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { blv.Close() })
+	return newIndexer(blv, config)
+}
+
+// indexTestRecord indexes a record of the given path, body and modification time
+func indexTestRecord(i *bleveIndexer, path, body string, modified time.Time) {
+	rec := i.Record(path).(*Record)
+	rec.SetTitle(path)
+	rec.SetBody([]byte(body))
+	rec.SetModified(modified)
+	i.Index(rec)
+}
+
+// storedPaths returns the sorted paths of the records in the index
+func storedPaths(t *testing.T, i *bleveIndexer) []string {
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), 100, 0, false)
+	result, err := i.bleve.Search(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, hit := range result.Hits {
+		paths = append(paths, hit.ID)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestDelete(t *testing.T) {
+	all := []string{"/a.epub", "/a.epub#ch1", "/a.epub#ch2", "/a.epubx", "/docs/b.md", "/docs/c/d.md", "/docsx.md"}
+	tests := []struct {
+		name   string
+		delete func(i *bleveIndexer) error
+		want   []string
+	}{
+		{"file and parts", func(i *bleveIndexer) error { return i.Delete("/a.epub") },
+			[]string{"/a.epubx", "/docs/b.md", "/docs/c/d.md", "/docsx.md"}},
+		{"part", func(i *bleveIndexer) error { return i.Delete("/a.epub#ch1") },
+			[]string{"/a.epub", "/a.epub#ch2", "/a.epubx", "/docs/b.md", "/docs/c/d.md", "/docsx.md"}},
+		{"directory", func(i *bleveIndexer) error { return i.DeletePrefix("/docs/") },
+			[]string{"/a.epub", "/a.epub#ch1", "/a.epub#ch2", "/a.epubx", "/docsx.md"}},
+		{"missing", func(i *bleveIndexer) error { return i.Delete("/missing.md") }, all},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := newTestIndexer(t, indexer.Config{})
+			for _, path := range all {
+				indexTestRecord(i, path, "some text", time.Now())
+			}
+			if err := test.delete(i); err != nil {
+				t.Fatal(err)
+			}
+			got := storedPaths(t, i)
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOpenIndex(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "index")
+	config := indexer.Config{Analyzer: "standard"}
+	open := func(config indexer.Config) *bleveIndexer {
+		blv, err := openIndex(name, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return newIndexer(blv, config)
+	}
+
+	// an index of an older version, without the mapping version
+	indexMap, err := indexMapping(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blv, err := bleve.New(name, indexMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexTestRecord(newIndexer(blv, config), "/old.md", "some text", time.Now())
+	blv.Close()
+
+	tests := []struct {
+		name   string
+		config indexer.Config
+		want   []string
+	}{
+		{"older version", config, nil},
+		{"same mapping", config, []string{"/a.md"}},
+		{"other options", indexer.Config{Analyzer: "standard", Languages: []string{"en"}}, nil},
+	}
+	for _, test := range tests {
+		i := open(test.config)
+		got := storedPaths(t, i)
+		indexTestRecord(i, "/a.md", "some text", time.Now())
+		i.bleve.Close()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSearchQueryError(t *testing.T) {
+	i := newTestIndexer(t, indexer.Config{})
+	indexTestRecord(i, "/a.md", "some text", time.Now())
+
+	resp := i.Search(indexer.SearchRequest{Query: `"some text`, Size: 10})
+	var queryErr *indexer.QueryError
+	if !errors.As(resp.Err, &queryErr) {
+		t.Errorf("got error %v, want a query error", resp.Err)
+	}
+	if resp := i.Search(indexer.SearchRequest{Query: `"some text"`, Size: 10}); resp.Err != nil || resp.Total != 1 {
+		t.Errorf("got %d hits and error %v, want 1 hit", resp.Total, resp.Err)
+	}
+}
+
+func TestSearchDateFacets(t *testing.T) {
+	now := time.Now()
+	yearAgo := now.AddDate(-1, 0, 0)
+	i := newTestIndexer(t, indexer.Config{})
+	indexTestRecord(i, "/hour.md", "some text", now.Add(-time.Hour))
+	indexTestRecord(i, "/days.md", "some text", now.AddDate(0, 0, -3))
+	indexTestRecord(i, "/years.md", "some text", now.AddDate(-2, 0, 0))
+
+	ranges := []indexer.DateRange{
+		{Name: "Past day", Start: now.AddDate(0, 0, -1)},
+		{Name: "Past week", Start: now.AddDate(0, 0, -7)},
+		{Name: "Past month", Start: now.AddDate(0, -1, 0)},
+		{Name: "Past year", Start: yearAgo},
+		{Name: "Older", End: yearAgo},
+	}
+	resp := i.Search(indexer.SearchRequest{
+		Query: "text",
+		Size:  10,
+		Facets: map[string]indexer.FacetRequest{
+			"Modified": {Field: "Modified", DateRanges: ranges},
+		},
+	})
+	if resp.Err != nil {
+		t.Fatal(resp.Err)
+	}
+
+	want := []int{1, 2, 2, 2, 1}
+	got := resp.Facets["Modified"].DateRanges
+	if len(got) != len(want) {
+		t.Fatalf("got %d date ranges, want %d", len(got), len(want))
+	}
+	for n, dr := range got {
+		if dr.Name != ranges[n].Name || dr.Count != want[n] {
+			t.Errorf("got %v %d, want %v %d", dr.Name, dr.Count, ranges[n].Name, want[n])
+		}
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	day := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	i := newTestIndexer(t, indexer.Config{})
+	for _, doc := range []struct {
+		path, mimetype string
+		modified       time.Time
+	}{
+		{"/blog/a.html", "text/html; charset=utf-8", day},
+		{"/blog/b.pdf", "application/pdf", day.AddDate(0, 0, -10)},
+		{"/docs/c.html", "text/html", day.AddDate(0, 0, 10)},
+	} {
+		rec := i.Record(doc.path).(*Record)
+		rec.SetBody([]byte("some text"))
+		rec.SetMimeType(doc.mimetype)
+		rec.SetModified(doc.modified)
+		i.Index(rec)
+	}
+
+	tests := []struct {
+		name    string
+		filters []indexer.Filter
+		want    []string
+	}{
+		{"none", nil, []string{"/blog/a.html", "/blog/b.pdf", "/docs/c.html"}},
+		{"path", []indexer.Filter{{Field: "PathKeyword", Prefix: "/blog/"}}, []string{"/blog/a.html", "/blog/b.pdf"}},
+		{"mime type", []indexer.Filter{{Field: "MimeType", Values: []string{"text/html"}}}, []string{"/blog/a.html", "/docs/c.html"}},
+		{"mime types", []indexer.Filter{{Field: "MimeType", Values: []string{"text/html", "application/pdf"}}},
+			[]string{"/blog/a.html", "/blog/b.pdf", "/docs/c.html"}},
+		{"after", []indexer.Filter{{Field: "Modified", Start: day}}, []string{"/blog/a.html", "/docs/c.html"}},
+		{"before", []indexer.Filter{{Field: "Modified", End: day}}, []string{"/blog/b.pdf"}},
+		{"between", []indexer.Filter{{Field: "Modified", Start: day.AddDate(0, 0, -1), End: day.AddDate(0, 0, 1)}},
+			[]string{"/blog/a.html"}},
+		{"all", []indexer.Filter{
+			{Field: "PathKeyword", Prefix: "/blog/"},
+			{Field: "MimeType", Values: []string{"text/html"}},
+			{Field: "Modified", Start: day},
+		}, []string{"/blog/a.html"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := i.Search(indexer.SearchRequest{Query: "text", Size: 10, Filters: test.filters})
+			if resp.Err != nil {
+				t.Fatal(resp.Err)
+			}
+			var got []string
+			for _, hit := range resp.Hits {
+				got = append(got, hit.Path())
+			}
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchFilteredSuggestion(t *testing.T) {
+	i := newTestIndexer(t, indexer.Config{})
+	indexTestRecord(i, "/docs/a.md", "search engine", time.Now())
+
+	tests := []struct {
+		query   string
+		filters []indexer.Filter
+		want    string
+	}{
+		{"serch", nil, "search"},
+		{"serch", []indexer.Filter{{Field: "PathKeyword", Prefix: "/blog/"}}, "search"},
+		// the filters exclude the hits of a query spelled right
+		{"search", []indexer.Filter{{Field: "PathKeyword", Prefix: "/blog/"}}, ""},
+	}
+	for _, test := range tests {
+		resp := i.Search(indexer.SearchRequest{Query: test.query, Size: 10, Filters: test.filters})
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		if resp.Total != 0 && test.filters != nil {
+			t.Fatalf("%q: got %d hits, want none", test.query, resp.Total)
+		}
+		if resp.Suggestion != test.want {
+			t.Errorf("%q %v: got suggestion %q, want %q", test.query, test.filters, resp.Suggestion, test.want)
+		}
+	}
+}
+
+func TestSearchHighlight(t *testing.T) {
+	i := newTestIndexer(t, indexer.Config{})
+	indexTestRecord(i, "/text.md", "a body holding the searched word", time.Now())
+
+	tests := []struct {
+		name    string
+		options *indexer.HighlightOptions
+		want    map[string][]string
+	}{
+		{"disabled", nil, nil},
+		{"default marks", &indexer.HighlightOptions{Fields: []string{"Body", "Title"}},
+			map[string][]string{"Body": {"a body holding the <mark>searched</mark> word"}}},
+		{"custom marks", &indexer.HighlightOptions{Before: "[", After: "]"},
+			map[string][]string{"Body": {"a body holding the [searched] word"}}},
+		{"other field", &indexer.HighlightOptions{Fields: []string{"Title"}}, map[string][]string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := i.Search(indexer.SearchRequest{Query: "searched", Size: 10, Highlight: test.options})
+			if resp.Err != nil {
+				t.Fatal(resp.Err)
+			}
+			if len(resp.Hits) != 1 {
+				t.Fatalf("got %d hits", len(resp.Hits))
+			}
+			if got := resp.Hits[0].Fragments; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueryText(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"caddy search", "caddy search"},
+		{"+caddy -apache", "caddy"},
+		{"Title:caddy Body:server", "caddy server"},
+		{`"caddy server" (web)`, "caddy server web"},
+		{"cadi~1 caddy^2 cad*", "cadi caddy cad"},
+		{"-apache", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := queryText(test.query); got != test.want {
+			t.Errorf("queryText(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestBoostQuery(t *testing.T) {
+	i := newTestIndexer(t, indexer.Config{})
+	for _, doc := range []struct{ path, title, body string }{
+		{"/title.md", "caddy", "a web server"},
+		{"/body.md", "other", "caddy caddy caddy is a web server"},
+	} {
+		rec := i.Record(doc.path).(*Record)
+		rec.SetTitle(doc.title)
+		rec.SetBody([]byte(doc.body))
+		i.Index(rec)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		boosts map[string]float64
+		want   []string
+	}{
+		{"body", "caddy", map[string]float64{"Body": 10}, []string{"/body.md", "/title.md"}},
+		{"title", "caddy", map[string]float64{"Title": 10}, []string{"/title.md", "/body.md"}},
+		{"matches only", "caddy", map[string]float64{"Title": 10, "Body": 1}, []string{"/title.md", "/body.md"}},
+		{"excluded", "web -caddy", map[string]float64{"Title": 10}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := i.Search(indexer.SearchRequest{Query: test.query, Size: 10, Boosts: test.boosts})
+			if resp.Err != nil {
+				t.Fatal(resp.Err)
+			}
+			var got []string
+			for _, hit := range resp.Hits {
+				got = append(got, hit.Path())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	q := bleve.NewMatchQuery("caddy")
+	if got := boostQuery(q, "-caddy", map[string]float64{"Title": 2}); got != q {
+		t.Errorf("got %v for a query without words, want the query", got)
+	}
+}
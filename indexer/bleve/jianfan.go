@@ -0,0 +1,54 @@
+package bleve
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/siongui/gojianfan"
+)
+
+// JianfanName is the type of the token filter converting between
+// Traditional and Simplified Chinese
+const JianfanName = "jianfan"
+
+// JianfanFilter converts the terms to Simplified Chinese or, when
+// toTraditional is true, to Traditional Chinese, so that both scripts match
+type JianfanFilter struct {
+	toTraditional bool
+}
+
+// Filter converts the terms of input
+func (f *JianfanFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, token := range input {
+		if token.Type != analysis.Ideographic {
+			continue
+		}
+		if f.toTraditional {
+			token.Term = []byte(gojianfan.S2T(string(token.Term)))
+		} else {
+			token.Term = []byte(gojianfan.T2S(string(token.Term)))
+		}
+	}
+	return input
+}
+
+// JianfanFilterConstructor builds a jianfan filter, its "direction" is "t2s"
+// (Traditional to Simplified, the default) or "s2t"
+func JianfanFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	direction, ok := config["direction"].(string)
+	if !ok {
+		direction = "t2s"
+	}
+	switch direction {
+	case "t2s":
+		return &JianfanFilter{}, nil
+	case "s2t":
+		return &JianfanFilter{toTraditional: true}, nil
+	}
+	return nil, fmt.Errorf("invalid jianfan direction %q", direction)
+}
+
+func init() {
+	registry.RegisterTokenFilter(JianfanName, JianfanFilterConstructor)
+}
@@ -0,0 +1,42 @@
+package bleve
+
+import (
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+)
+
+func TestJianfanFilter(t *testing.T) {
+	tests := []struct {
+		direction string
+		term      string
+		tokenType analysis.TokenType
+		want      string
+	}{
+		{"t2s", "我們說國語", analysis.Ideographic, "我们说国语"},
+		{"t2s", "我们说国语", analysis.Ideographic, "我们说国语"},
+		{"s2t", "我们说国语", analysis.Ideographic, "我們說國語"},
+		{"s2t", "我們說國語", analysis.Ideographic, "我們說國語"},
+		{"", "這個", analysis.Ideographic, "这个"},
+		// only the ideographic tokens are converted
+		{"t2s", "這個", analysis.AlphaNumeric, "這個"},
+	}
+	for _, test := range tests {
+		config := map[string]interface{}{}
+		if test.direction != "" {
+			config["direction"] = test.direction
+		}
+		filter, err := JianfanFilterConstructor(config, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output := filter.Filter(analysis.TokenStream{{Term: []byte(test.term), Type: test.tokenType}})
+		if got := string(output[0].Term); got != test.want {
+			t.Errorf("%v %q: got %q, want %q", test.direction, test.term, got, test.want)
+		}
+	}
+
+	if _, err := JianfanFilterConstructor(map[string]interface{}{"direction": "x2y"}, nil); err == nil {
+		t.Error("got no error for an invalid direction")
+	}
+}
@@ -0,0 +1,93 @@
+package bleve
+
+import (
+	"fmt"
+	"strings"
+
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/es"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/it"
+	"github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	"github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ru"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// languageAnalyzers maps the languages whose body can be indexed on its own
+// to their analyzer
+var languageAnalyzers = map[string]string{
+	"en": en.AnalyzerName,
+	"de": de.AnalyzerName,
+	"fr": fr.AnalyzerName,
+	"es": es.AnalyzerName,
+	"it": it.AnalyzerName,
+	"nl": nl.AnalyzerName,
+	"pt": pt.AnalyzerName,
+	"ru": ru.AnalyzerName,
+	"zh": "sego",
+	"ja": cjk.AnalyzerName,
+	"ko": cjk.AnalyzerName,
+}
+
+// primaryLanguage returns the primary subtag of a language tag, e.g. zh for zh-CN
+func primaryLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if n := strings.IndexAny(lang, "-_"); n >= 0 {
+		lang = lang[:n]
+	}
+	return lang
+}
+
+// bodiesMapping maps the Bodies.<language> fields, indexed with the analyzer
+// of their language unless fieldAnalyzers sets another one. They are not
+// stored, hits are highlighted in Body.
+func bodiesMapping(languages []string, fieldAnalyzers map[string]string) (*mapping.DocumentMapping, error) {
+	bodies := bleve.NewDocumentMapping()
+	for _, lang := range languages {
+		analyzer, ok := languageAnalyzers[lang]
+		if !ok {
+			return nil, fmt.Errorf("unsupported language %q", lang)
+		}
+		if fieldAnalyzer := fieldAnalyzers["Bodies."+lang]; fieldAnalyzer != "" {
+			analyzer = fieldAnalyzer
+		}
+		field := bleve.NewTextFieldMapping()
+		field.Analyzer = analyzer
+		field.Store = false
+		field.IncludeInAll = false
+		bodies.AddFieldMappingsAt(lang, field)
+	}
+	return bodies, nil
+}
+
+// bodies returns the body of rec keyed by its language, if it is one of languages
+func bodies(rec *Record, languages []string) map[string]string {
+	lang := primaryLanguage(rec.Meta("lang"))
+	for _, l := range languages {
+		if l == lang {
+			return map[string]string{lang: string(rec.body)}
+		}
+	}
+	return nil
+}
+
+// languagesQuery matches q or, for queries without syntax, its words in the
+// body of the documents of any of languages
+func languagesQuery(q query.Query, queryString string, languages []string) query.Query {
+	text := queryText(queryString)
+	if text == "" || text != strings.Join(strings.Fields(queryString), " ") {
+		return q
+	}
+	disjuncts := []query.Query{q}
+	for _, lang := range languages {
+		match := bleve.NewMatchQuery(text)
+		match.SetField("Bodies." + lang)
+		disjuncts = append(disjuncts, match)
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
@@ -0,0 +1,84 @@
+package bleve
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+func TestPrimaryLanguage(t *testing.T) {
+	for lang, want := range map[string]string{
+		"en":      "en",
+		" EN-us ": "en",
+		"zh_Hans": "zh",
+		"":        "",
+	} {
+		if got := primaryLanguage(lang); got != want {
+			t.Errorf("primaryLanguage(%q) = %q, want %q", lang, got, want)
+		}
+	}
+}
+
+func TestBodies(t *testing.T) {
+	tests := []struct {
+		lang string
+		want map[string]string
+	}{
+		{"en-GB", map[string]string{"en": "some text"}},
+		{"fr", map[string]string{"fr": "some text"}},
+		{"de", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		rec := &Record{body: []byte("some text"), meta: map[string]string{"lang": test.lang}}
+		if got := bodies(rec, []string{"en", "fr"}); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("bodies with lang %q = %v, want %v", test.lang, got, test.want)
+		}
+	}
+}
+
+func TestSearchLanguages(t *testing.T) {
+	i := newTestIndexer(t, indexer.Config{Languages: []string{"en", "fr"}})
+	for _, doc := range []struct{ path, lang, body string }{
+		{"/en.html", "en", "the servers are running"},
+		{"/fr.html", "fr-FR", "les serveurs tournent"},
+		{"/none.html", "", "running servers"},
+	} {
+		rec := i.Record(doc.path).(*Record)
+		rec.SetBody([]byte(doc.body))
+		rec.SetMeta("lang", doc.lang)
+		i.Index(rec)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// stemmed in the body of the documents of the language
+		{"run", []string{"/en.html"}},
+		{"serveur", []string{"/fr.html"}},
+		{"running", []string{"/en.html", "/none.html"}},
+		// queries with syntax are left alone
+		{"+run", nil},
+	}
+	for _, test := range tests {
+		resp := i.Search(indexer.SearchRequest{Query: test.query, Size: 10})
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		var got []string
+		for _, hit := range resp.Hits {
+			got = append(got, hit.Path())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.query, got, test.want)
+		}
+	}
+
+	if _, err := indexMapping(indexer.Config{Languages: []string{"xx"}}); err == nil {
+		t.Error("got no error for an unsupported language")
+	}
+}
@@ -0,0 +1,114 @@
+package bleve
+
+import (
+	"strings"
+
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/simple"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/mozillazg/go-pinyin"
+)
+
+// PinyinName is the type of the token filter replacing Chinese terms by their pinyin
+const PinyinName = "pinyin"
+
+// PinyinFilter replaces each Chinese term by its full pinyin without tones
+// (beijing), its initials (bj) and, for several characters, each syllable
+// (bei, jing), all at the position of the term. Other terms are dropped.
+type PinyinFilter struct {
+	args pinyin.Args
+}
+
+// Filter replaces the terms of input by their pinyin
+func (f *PinyinFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	output := make(analysis.TokenStream, 0, len(input)*2)
+	for _, token := range input {
+		if token.Type != analysis.Ideographic {
+			continue
+		}
+		syllables := make([]string, 0)
+		initials := make([]byte, 0)
+		for _, syllable := range pinyin.LazyPinyin(string(token.Term), f.args) {
+			if syllable != "" {
+				syllables = append(syllables, syllable)
+				initials = append(initials, syllable[0])
+			}
+		}
+		if len(syllables) == 0 {
+			continue
+		}
+		terms := []string{strings.Join(syllables, ""), string(initials)}
+		if len(syllables) > 1 {
+			terms = append(terms, syllables...)
+		}
+		for _, term := range terms {
+			output = append(output, &analysis.Token{
+				Term:     []byte(term),
+				Start:    token.Start,
+				End:      token.End,
+				Position: token.Position,
+				Type:     analysis.AlphaNumeric,
+			})
+		}
+	}
+	return output
+}
+
+// PinyinFilterConstructor builds a pinyin filter
+func PinyinFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	return &PinyinFilter{args: pinyin.NewArgs()}, nil
+}
+
+func init() {
+	registry.RegisterTokenFilter(PinyinName, PinyinFilterConstructor)
+}
+
+// addPinyinAnalyzer registers the pinyin analyzer, segmenting with sego
+func addPinyinAnalyzer(indexMapping *mapping.IndexMappingImpl) error {
+	return indexMapping.AddCustomAnalyzer("pinyin",
+		map[string]interface{}{
+			"type":          custom.Name,
+			"tokenizer":     "sego",
+			"token_filters": []string{StopZhName, PinyinName},
+		})
+}
+
+// pinyinMapping indexes a text field again in the Pinyin field
+func pinyinMapping() *mapping.FieldMapping {
+	fieldMapping := bleve.NewTextFieldMapping()
+	fieldMapping.Name = "Pinyin"
+	fieldMapping.Analyzer = "pinyin"
+	fieldMapping.Store = false
+	fieldMapping.IncludeInAll = false
+	return fieldMapping
+}
+
+// isLatin reports if text is made of ASCII letters and spaces only
+func isLatin(text string) bool {
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	for _, r := range text {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == ' ') {
+			return false
+		}
+	}
+	return true
+}
+
+// pinyinQuery matches q or, for Latin-only queries, the documents whose title
+// or body reads like the query in pinyin, e.g. beijing or bj for 北京
+func pinyinQuery(q query.Query, queryString string) query.Query {
+	if !isLatin(queryString) {
+		return q
+	}
+	match := bleve.NewMatchQuery(queryString)
+	match.SetField("Pinyin")
+	match.Analyzer = simple.Name
+	match.SetOperator(query.MatchQueryOperatorAnd)
+	return bleve.NewDisjunctionQuery(q, match)
+}
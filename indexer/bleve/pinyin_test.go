@@ -0,0 +1,91 @@
+package bleve
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+func TestPinyinFilter(t *testing.T) {
+	filter, err := PinyinFilterConstructor(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := analysis.TokenStream{
+		{Term: []byte("北京"), Start: 0, End: 6, Position: 1, Type: analysis.Ideographic},
+		{Term: []byte("caddy"), Start: 6, End: 11, Position: 2, Type: analysis.AlphaNumeric},
+		{Term: []byte("中"), Start: 11, End: 14, Position: 3, Type: analysis.Ideographic},
+	}
+	type term struct {
+		text     string
+		position int
+		start    int
+	}
+	want := []term{
+		{"beijing", 1, 0}, {"bj", 1, 0}, {"bei", 1, 0}, {"jing", 1, 0},
+		{"zhong", 3, 11}, {"z", 3, 11},
+	}
+	var got []term
+	for _, token := range filter.Filter(input) {
+		if token.Type != analysis.AlphaNumeric {
+			t.Errorf("got type %v for %q", token.Type, token.Term)
+		}
+		got = append(got, term{string(token.Term), token.Position, token.Start})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIsLatin(t *testing.T) {
+	for text, want := range map[string]bool{
+		"beijing":    true,
+		"Bei Jing":   true,
+		"":           false,
+		"  ":         false,
+		"beijing2":   false,
+		"北京":         false,
+		"title:bj":   false,
+		"beijing 北京": false,
+	} {
+		if got := isLatin(text); got != want {
+			t.Errorf("isLatin(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestSearchPinyin(t *testing.T) {
+	i := newTestIndexer(t, indexer.Config{Analyzer: "sego", Pinyin: true})
+	indexTestRecord(i, "/1.md", "我爱北京", time.Now())
+	indexTestRecord(i, "/2.md", "我爱上海", time.Now())
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"beijing", []string{"/1.md"}},
+		{"bj", []string{"/1.md"}},
+		{"bei jing", []string{"/1.md"}},
+		{"Shanghai", []string{"/2.md"}},
+		{"北京", []string{"/1.md"}},
+		{"beijing shanghai", nil},
+	}
+	for _, test := range tests {
+		resp := i.Search(indexer.SearchRequest{Query: test.query, Size: 10})
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		var got []string
+		for _, hit := range resp.Hits {
+			got = append(got, hit.Path())
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.query, got, test.want)
+		}
+	}
+}
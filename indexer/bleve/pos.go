@@ -0,0 +1,84 @@
+package bleve
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+)
+
+// PosFilter selects the sego tokens by their part of speech tag, e.g. n, nr or
+// uj. Tags are matched by prefix, so n stands for all the nouns. Tokens without
+// a tag or tagged x (words outside the dictionaries, Latin words, numbers...)
+// are always kept.
+type PosFilter struct {
+	// Drop are the tags of the tokens dropped
+	Drop []string
+	// Keep are the tags of the only tokens kept, all if empty
+	Keep []string
+}
+
+// Allows reports if a token tagged tag is kept
+func (f *PosFilter) Allows(tag string) bool {
+	if tag == "" || tag == "x" {
+		return true
+	}
+	for _, drop := range f.Drop {
+		if strings.HasPrefix(tag, drop) {
+			return false
+		}
+	}
+	if len(f.Keep) == 0 {
+		return true
+	}
+	for _, keep := range f.Keep {
+		if strings.HasPrefix(tag, keep) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the tokens of input allowed by their tag in tags, which are
+// in the same order. The positions of the dropped tokens are left empty.
+func (f *PosFilter) Filter(input analysis.TokenStream, tags []string) analysis.TokenStream {
+	output := make(analysis.TokenStream, 0, len(input))
+	for n, token := range input {
+		if n < len(tags) && !f.Allows(tags[n]) {
+			continue
+		}
+		output = append(output, token)
+	}
+	return output
+}
+
+// posFilter returns the part of speech filter of a tokenizer config, given by
+// its "pos_drop" and "pos_keep" lists of tags, nil if none
+func posFilter(config map[string]interface{}) *PosFilter {
+	filter := &PosFilter{
+		Drop: stringList(config["pos_drop"]),
+		Keep: stringList(config["pos_keep"]),
+	}
+	if len(filter.Drop) == 0 && len(filter.Keep) == 0 {
+		return nil
+	}
+	return filter
+}
+
+// stringList returns the strings of a config value, a list or a space separated string
+func stringList(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []string:
+		return value
+	case []interface{}:
+		list := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package bleve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+)
+
+func TestPosFilterAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter PosFilter
+		tags   map[string]bool
+	}{
+		{"none", PosFilter{}, map[string]bool{"n": true, "uj": true, "": true}},
+		{"drop", PosFilter{Drop: []string{"u", "y"}},
+			map[string]bool{"uj": false, "ul": false, "y": false, "n": true, "v": true, "": true, "x": true}},
+		{"keep", PosFilter{Keep: []string{"n", "v"}},
+			map[string]bool{"n": true, "nr": true, "ns": true, "vn": true, "v": true, "r": false, "uj": false, "": true, "x": true}},
+		{"drop and keep", PosFilter{Drop: []string{"nr"}, Keep: []string{"n"}},
+			map[string]bool{"n": true, "ns": true, "nr": false, "nrt": false, "v": false, "x": true}},
+	}
+	for _, test := range tests {
+		for tag, want := range test.tags {
+			if got := test.filter.Allows(tag); got != want {
+				t.Errorf("%v: Allows(%q) = %v, want %v", test.name, tag, got, want)
+			}
+		}
+	}
+}
+
+func TestPosFilterFilter(t *testing.T) {
+	filter := &PosFilter{Drop: []string{"u"}}
+	input := analysis.TokenStream{
+		{Term: []byte("我"), Position: 1},
+		{Term: []byte("的"), Position: 2},
+		{Term: []byte("书"), Position: 3},
+		{Term: []byte("caddy"), Position: 4},
+	}
+	var got []string
+	for _, token := range filter.Filter(input, []string{"r", "uj", "n"}) {
+		got = append(got, string(token.Term))
+	}
+	// the tokens without a tag are kept
+	if want := []string{"我", "书", "caddy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPosFilterConfig(t *testing.T) {
+	tests := []struct {
+		config map[string]interface{}
+		want   *PosFilter
+	}{
+		{map[string]interface{}{}, nil},
+		{map[string]interface{}{"pos_drop": []interface{}{}, "pos_keep": ""}, nil},
+		{map[string]interface{}{"pos_drop": "u y"}, &PosFilter{Drop: []string{"u", "y"}}},
+		{map[string]interface{}{"pos_keep": []interface{}{"n", "", 3, "v"}}, &PosFilter{Drop: nil, Keep: []string{"n", "v"}}},
+		{map[string]interface{}{"pos_drop": []string{"u"}, "pos_keep": []string{"n"}}, &PosFilter{Drop: []string{"u"}, Keep: []string{"n"}}},
+	}
+	for _, test := range tests {
+		if got := posFilter(test.config); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("posFilter(%v) = %v, want %v", test.config, got, test.want)
+		}
+	}
+}
+
+func TestSegoTokenizerPos(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   []string
+	}{
+		{"all", map[string]interface{}{"search": false}, []string{"我们", "喜欢", "的", "搜索引擎"}},
+		{"drop", map[string]interface{}{"search": false, "pos_drop": []interface{}{"u"}}, []string{"我们", "喜欢", "搜索引擎"}},
+		{"keep", map[string]interface{}{"search": false, "pos_keep": []interface{}{"n", "v"}}, []string{"喜欢", "搜索引擎"}},
+	}
+	for _, test := range tests {
+		if got := tokenTerms(t, test.config, "我们喜欢的搜索引擎"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package bleve
 
 import (
+	"strings"
 	"time"
 
 	index "github.com/blevesearch/bleve_index_api"
@@ -19,6 +20,7 @@ type Record struct {
 	ignored  bool
 	indexed  time.Time
 	mimetype string
+	meta     map[string]string
 }
 
 // Path returns Record's path
@@ -83,6 +85,12 @@ func (r *Record) Load() bool {
 		return false
 	}
 
+	r.load(doc)
+	return true
+}
+
+// load fills the record with the stored fields of doc
+func (r *Record) load(doc index.Document) {
 	result := make(map[string]index.Field)
 
 	doc.VisitFields(func(field index.Field) {
@@ -96,10 +104,24 @@ func (r *Record) Load() bool {
 
 	r.SetBody(result["Body"].Value())
 	r.title = string(result["Title"].Value())
+	if f := result["FullPath"]; f != nil {
+		r.fullPath = string(f.Value())
+	}
+	if f := result["MimeType"]; f != nil {
+		r.mimetype = string(f.Value())
+	}
+	for key, field := range metaFields {
+		if f := result[field]; f != nil {
+			r.SetMeta(key, string(f.Value()))
+		}
+	}
+	for name, f := range result {
+		if strings.HasPrefix(name, "OpenGraph.") {
+			r.SetMeta("og:"+strings.TrimPrefix(name, "OpenGraph."), string(f.Value()))
+		}
+	}
 
 	r.loaded = true
-
-	return true
 }
 
 // Write is the writing method for a Record
@@ -135,3 +157,20 @@ func (r *Record) MimeType() string {
 func (r *Record) SetMimeType(val string) {
 	r.mimetype = val
 }
+
+// Meta returns the metadata stored under key, "" if there is none
+func (r *Record) Meta(key string) string {
+	return r.meta[key]
+}
+
+// SetMeta stores the metadata value under key, an empty value removes it
+func (r *Record) SetMeta(key string, value string) {
+	if value == "" {
+		delete(r.meta, key)
+		return
+	}
+	if r.meta == nil {
+		r.meta = make(map[string]string)
+	}
+	r.meta[key] = value
+}
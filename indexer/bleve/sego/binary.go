@@ -0,0 +1,249 @@
+package sego
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/adamzy/cedar-go"
+)
+
+// 二进制词典文件的标识和格式版本，格式变化时需增加版本号
+const (
+	binaryDictionaryMagic   = "SEGODICT"
+	binaryDictionaryVersion = 1
+)
+
+// 子分词指向词典外的伪分词（单个字元）时的分词序号
+const pseudoToken = math.MaxUint32
+
+var errBinaryDictionary = errors.New("sego: 无效的二进制词典")
+
+// 将词典以二进制格式保存，包括所有分词及其子分词划分和前缀树，
+// 用LoadBinaryDictionary载入时无需重新解析文本词典和划分子分词
+//
+// 格式（小端序）：
+//
+//	标识 版本 最长分词 总词频 分词数
+//	每个分词：字元数 字元... 词频 路径值 词性 子分词数 子分词...
+//	每个子分词：起始位置 结束位置 分词序号（伪分词时为0xffffffff，后跟伪分词）
+//	前缀树（gob编码）
+func (seg *Segmenter) SaveDictionary(w io.Writer) error {
+	dict := seg.dict
+	if dict == nil {
+		return errors.New("sego: 词典未载入")
+	}
+
+	// 子分词指向的词典分词的序号
+	indexes := make(map[*Token]int, len(dict.tokens))
+	for i := range dict.tokens {
+		indexes[&dict.tokens[i]] = i
+	}
+
+	bw := bufio.NewWriter(w)
+	out := &binaryWriter{w: bw}
+	out.bytes([]byte(binaryDictionaryMagic))
+	out.uint32(binaryDictionaryVersion)
+	out.uint32(uint32(dict.maxTokenLength))
+	out.uint64(uint64(dict.totalFrequency))
+	out.uint32(uint32(len(dict.tokens)))
+	for i := range dict.tokens {
+		token := &dict.tokens[i]
+		out.token(token)
+		out.uint32(uint32(len(token.segments)))
+		for _, segment := range token.segments {
+			out.uint32(uint32(segment.start))
+			out.uint32(uint32(segment.end))
+			index, ok := indexes[segment.token]
+			if !ok {
+				out.uint32(pseudoToken)
+				out.token(segment.token)
+				continue
+			}
+			out.uint32(uint32(index))
+		}
+	}
+	if out.err != nil {
+		return out.err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	// 前缀树放在最后，gob解码时可能预读
+	return dict.trie.Save(w, "gob")
+}
+
+// 载入SaveDictionary保存的二进制词典
+func (seg *Segmenter) LoadBinaryDictionary(r io.Reader) error {
+	br := bufio.NewReader(r)
+	in := &binaryReader{r: br}
+	if string(in.bytes(len(binaryDictionaryMagic))) != binaryDictionaryMagic || in.err != nil {
+		return errBinaryDictionary
+	}
+	if in.uint32() != binaryDictionaryVersion || in.err != nil {
+		return errBinaryDictionary
+	}
+
+	dict := NewDictionary()
+	dict.maxTokenLength = int(in.uint32())
+	dict.totalFrequency = int64(in.uint64())
+	numTokens := int(in.uint32())
+	if in.err != nil {
+		return in.err
+	}
+	// 分词数来自文件，分词切片随实际读到的分词增长，防止损坏的文件导致分配过大的内存
+	capacity := numTokens
+	if capacity > maxBinaryPrealloc {
+		capacity = maxBinaryPrealloc
+	}
+	dict.tokens = make([]Token, 0, capacity)
+	// 子分词指向的分词可能尚未读到，全部读完后再设置
+	type tokenLink struct {
+		segment *Segment
+		index   int
+	}
+	links := make([]tokenLink, 0)
+	for i := 0; i < numTokens && in.err == nil; i++ {
+		var token Token
+		in.token(&token)
+		numSegments := int(in.uint32())
+		if in.err != nil || numSegments > len(token.text) {
+			return errBinaryDictionary
+		}
+		token.segments = make([]*Segment, numSegments)
+		for j := range token.segments {
+			segment := &Segment{start: int(in.uint32()), end: int(in.uint32())}
+			index := in.uint32()
+			switch {
+			case index == pseudoToken:
+				segment.token = &Token{}
+				in.token(segment.token)
+			case int(index) < numTokens:
+				links = append(links, tokenLink{segment, int(index)})
+			default:
+				return errBinaryDictionary
+			}
+			token.segments[j] = segment
+		}
+		dict.tokens = append(dict.tokens, token)
+	}
+	if in.err != nil {
+		return in.err
+	}
+	for _, link := range links {
+		link.segment.token = &dict.tokens[link.index]
+	}
+
+	// 前缀树紧随其后
+	trie := cedar.New()
+	if err := trie.Load(br, "gob"); err != nil {
+		return err
+	}
+	dict.trie = trie
+	seg.dict = dict
+	return nil
+}
+
+// 二进制词典写入，出错后忽略后续写入
+type binaryWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (out *binaryWriter) bytes(b []byte) {
+	if out.err == nil {
+		_, out.err = out.w.Write(b)
+	}
+}
+
+func (out *binaryWriter) uint32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	out.bytes(b[:])
+}
+
+func (out *binaryWriter) uint64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	out.bytes(b[:])
+}
+
+func (out *binaryWriter) text(text []byte) {
+	out.uint32(uint32(len(text)))
+	out.bytes(text)
+}
+
+// 写入分词的字元、词频、路径值和词性，不含子分词
+func (out *binaryWriter) token(token *Token) {
+	out.uint32(uint32(len(token.text)))
+	for _, word := range token.text {
+		out.text(word)
+	}
+	out.uint64(uint64(token.frequency))
+	out.uint32(math.Float32bits(token.distance))
+	out.text([]byte(token.pos))
+}
+
+// 二进制词典读取，出错后后续读取返回零值
+type binaryReader struct {
+	r   io.Reader
+	err error
+}
+
+func (in *binaryReader) bytes(n int) []byte {
+	if in.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	_, in.err = io.ReadFull(in.r, b)
+	return b
+}
+
+func (in *binaryReader) uint32() uint32 {
+	b := in.bytes(4)
+	if in.err != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (in *binaryReader) uint64() uint64 {
+	b := in.bytes(8)
+	if in.err != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// 最长字串，防止损坏的文件导致分配过大的内存
+const maxBinaryText = 1 << 16
+
+// 预先分配的最多分词数，更多的分词随读取增长
+const maxBinaryPrealloc = 1 << 20
+
+func (in *binaryReader) text() []byte {
+	n := in.uint32()
+	if in.err == nil && n > maxBinaryText {
+		in.err = errBinaryDictionary
+	}
+	return in.bytes(int(n))
+}
+
+// 读取分词的字元、词频、路径值和词性，不含子分词
+func (in *binaryReader) token(token *Token) {
+	numWords := in.uint32()
+	if in.err == nil && numWords > maxBinaryText {
+		in.err = errBinaryDictionary
+		return
+	}
+	token.text = make([]Text, 0, numWords)
+	for i := uint32(0); i < numWords && in.err == nil; i++ {
+		token.text = append(token.text, in.text())
+	}
+	token.frequency = int(in.uint64())
+	token.distance = math.Float32frombits(in.uint32())
+	token.pos = string(in.text())
+}
@@ -0,0 +1,89 @@
+package sego
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const binaryTestDict = `中国 100 ns
+人口 80 n
+十三亿 20 m
+有 60 v
+中华人民共和国 50 ns
+中华 30 nz
+人民 90 n
+共和国 40 ns
+`
+
+func TestBinaryDictionary(t *testing.T) {
+	var seg Segmenter
+	if err := seg.LoadDictionaryFromReaders([]io.Reader{strings.NewReader(binaryTestDict)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := seg.SaveDictionary(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded Segmenter
+	if err := loaded.LoadBinaryDictionary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, "8", loaded.dict.NumTokens())
+	expect(t, strconv.FormatInt(seg.dict.TotalFrequency(), 10), loaded.dict.TotalFrequency())
+	expect(t, strconv.Itoa(seg.dict.MaxTokenLength()), loaded.dict.MaxTokenLength())
+
+	for _, text := range []string{"中国有十三亿人口", "中华人民共和国", "中华人民共和国的人口"} {
+		for _, searchMode := range []bool{false, true} {
+			expect(t,
+				SegmentsToString(seg.InternalSegment([]byte(text), searchMode), searchMode),
+				SegmentsToString(loaded.InternalSegment([]byte(text), searchMode), searchMode))
+		}
+	}
+}
+
+func TestBinaryDictionaryInvalid(t *testing.T) {
+	var seg Segmenter
+	if err := seg.LoadBinaryDictionary(strings.NewReader("中国 100 ns\n")); err == nil {
+		t.Error("期待错误")
+	}
+	if err := seg.LoadBinaryDictionary(strings.NewReader(binaryDictionaryMagic)); err == nil {
+		t.Error("期待错误")
+	}
+}
+
+func TestBinaryDictionaryCorrupt(t *testing.T) {
+	// 声称有大量分词的文件头，不应按分词数分配内存
+	var header bytes.Buffer
+	out := &binaryWriter{w: &header}
+	out.bytes([]byte(binaryDictionaryMagic))
+	out.uint32(binaryDictionaryVersion)
+	out.uint32(7)
+	out.uint64(1000)
+	out.uint32(math.MaxUint32 - 1)
+	var seg Segmenter
+	if err := seg.LoadBinaryDictionary(&header); err == nil {
+		t.Error("期待错误")
+	}
+
+	// 截断的词典
+	var dict Segmenter
+	if err := dict.LoadDictionaryFromReaders([]io.Reader{strings.NewReader(binaryTestDict)}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := dict.SaveDictionary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{len(binaryDictionaryMagic) + 24, buf.Len() / 2, buf.Len() - 1} {
+		var loaded Segmenter
+		if err := loaded.LoadBinaryDictionary(bytes.NewReader(buf.Bytes()[:size])); err == nil {
+			t.Errorf("截断到%d字节，期待错误", size)
+		}
+	}
+}
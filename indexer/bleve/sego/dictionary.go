@@ -1,6 +1,10 @@
 package sego
 
-import "github.com/adamzy/cedar-go"
+import (
+	"sync"
+
+	"github.com/adamzy/cedar-go"
+)
 
 // Dictionary结构体实现了一个字串前缀树，一个分词可能出现在叶子节点也有可能出现在非叶节点
 type Dictionary struct {
@@ -8,6 +12,10 @@ type Dictionary struct {
 	maxTokenLength int          // 词典中最长的分词
 	tokens         []Token      // 词典中所有的分词，方便遍历
 	totalFrequency int64        // 词典中所有分词的频率之和
+
+	// 未登录词识别模型，首次使用时从词典训练，此后分词时无需加锁
+	hmmOnce sync.Once
+	hmm     *hmmModel
 }
 
 func NewDictionary() *Dictionary {
@@ -37,6 +45,14 @@ func (dict *Dictionary) Close() {
 	dict.totalFrequency = int64(0)
 }
 
+// 返回词典的未登录词识别模型，首次调用时训练
+func (dict *Dictionary) hmmModel() *hmmModel {
+	dict.hmmOnce.Do(func() {
+		dict.hmm = newHMMModel(dict)
+	})
+	return dict.hmm
+}
+
 // 向词典中加入一个分词
 func (dict *Dictionary) addToken(token Token) {
 	bytes := textSliceToBytes(token.text)
@@ -0,0 +1,227 @@
+package sego
+
+import (
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 隐马尔可夫模型的状态：词首、词中、词尾和单字成词
+const (
+	stateB = iota
+	stateM
+	stateE
+	stateS
+	numStates
+)
+
+// 未登录词识别用的隐马尔可夫模型，各概率均取对数
+type hmmModel struct {
+	start [numStates]float64
+	trans [numStates][numStates]float64
+	emit  [numStates]map[rune]float64
+	// 模型中未出现的字在各状态下的发射概率
+	unseen [numStates]float64
+}
+
+// 从词典的分词和词频训练模型，只统计全部由汉字组成的分词
+//
+// 各字在词中的位置给出发射概率，词长给出词内的状态转移概率，
+// 多字词和单字词的词频之比给出词间的状态转移概率和初始概率。
+func newHMMModel(dict *Dictionary) *hmmModel {
+	var emitCounts [numStates]map[rune]float64
+	var emitTotals [numStates]float64
+	for s := range emitCounts {
+		emitCounts[s] = make(map[rune]float64)
+	}
+	var transCounts [numStates][numStates]float64
+	var multi, single float64
+	chars := make(map[rune]bool)
+
+	for i := range dict.tokens {
+		token := &dict.tokens[i]
+		runes, ok := hanRunes(token.text)
+		if !ok {
+			continue
+		}
+		f := float64(token.frequency)
+		for _, r := range runes {
+			chars[r] = true
+		}
+		n := len(runes)
+		if n == 1 {
+			single += f
+			emitCounts[stateS][runes[0]] += f
+			emitTotals[stateS] += f
+			continue
+		}
+		multi += f
+		emitCounts[stateB][runes[0]] += f
+		emitTotals[stateB] += f
+		for _, r := range runes[1 : n-1] {
+			emitCounts[stateM][r] += f
+			emitTotals[stateM] += f
+		}
+		emitCounts[stateE][runes[n-1]] += f
+		emitTotals[stateE] += f
+
+		if n == 2 {
+			transCounts[stateB][stateE] += f
+		} else {
+			transCounts[stateB][stateM] += f
+			transCounts[stateM][stateM] += f * float64(n-3)
+			transCounts[stateM][stateE] += f
+		}
+	}
+
+	model := &hmmModel{}
+	pMulti := 0.5
+	if multi+single > 0 {
+		pMulti = multi / (multi + single)
+	}
+	model.start = [numStates]float64{math.Log(pMulti), math.Inf(-1), math.Inf(-1), math.Log(1 - pMulti)}
+	for s := range model.trans {
+		for t := range model.trans[s] {
+			model.trans[s][t] = math.Inf(-1)
+		}
+	}
+	for _, s := range []int{stateE, stateS} {
+		model.trans[s][stateB] = math.Log(pMulti)
+		model.trans[s][stateS] = math.Log(1 - pMulti)
+	}
+	for _, s := range []int{stateB, stateM} {
+		total := transCounts[s][stateM] + transCounts[s][stateE]
+		if total == 0 {
+			// 无此类分词时取等概率
+			model.trans[s][stateM] = math.Log(0.5)
+			model.trans[s][stateE] = math.Log(0.5)
+			continue
+		}
+		model.trans[s][stateM] = math.Log(transCounts[s][stateM] / total)
+		model.trans[s][stateE] = math.Log(transCounts[s][stateE] / total)
+	}
+
+	// 加一平滑，未出现的字也有非零的发射概率
+	vocabulary := float64(len(chars) + 1)
+	for s := range model.emit {
+		model.emit[s] = make(map[rune]float64, len(emitCounts[s]))
+		for r, count := range emitCounts[s] {
+			model.emit[s][r] = math.Log((count + 1) / (emitTotals[s] + vocabulary))
+		}
+		model.unseen[s] = math.Log(1 / (emitTotals[s] + vocabulary))
+	}
+	return model
+}
+
+// 状态s发射字r的概率
+func (model *hmmModel) emission(s int, r rune) float64 {
+	if p, ok := model.emit[s][r]; ok {
+		return p
+	}
+	return model.unseen[s]
+}
+
+// 用Viterbi算法求字串最可能的状态序列，序列以词首或单字开始，以词尾或单字结束
+func (model *hmmModel) viterbi(runes []rune) []int {
+	n := len(runes)
+	prob := make([][numStates]float64, n)
+	prev := make([][numStates]int, n)
+	for s := 0; s < numStates; s++ {
+		prob[0][s] = model.start[s] + model.emission(s, runes[0])
+	}
+	for i := 1; i < n; i++ {
+		for s := 0; s < numStates; s++ {
+			best, arg := math.Inf(-1), stateS
+			for p := 0; p < numStates; p++ {
+				if v := prob[i-1][p] + model.trans[p][s]; v > best {
+					best, arg = v, p
+				}
+			}
+			prob[i][s] = best + model.emission(s, runes[i])
+			prev[i][s] = arg
+		}
+	}
+
+	states := make([]int, n)
+	states[n-1] = stateS
+	if prob[n-1][stateE] > prob[n-1][stateS] {
+		states[n-1] = stateE
+	}
+	for i := n - 1; i > 0; i-- {
+		states[i-1] = prev[i][states[i]]
+	}
+	return states
+}
+
+// 用模型合并分词结果中连续的单字，识别词典中没有的词
+//
+// 只处理由两个以上单个汉字组成的片段，识别出的新词词性为"x"，没有子分词；
+// 仍为单字的保留原有分词信息。
+func (model *hmmModel) recognize(segments []Segment) []Segment {
+	output := make([]Segment, 0, len(segments))
+	for i := 0; i < len(segments); {
+		j := i
+		for j < len(segments) && isSingleHan(segments[j].token) {
+			j++
+		}
+		if j-i < 2 {
+			if j == i {
+				j++
+			}
+			output = append(output, segments[i:j]...)
+			i = j
+			continue
+		}
+
+		run := segments[i:j]
+		runes := make([]rune, len(run))
+		for k := range run {
+			runes[k], _ = utf8.DecodeRune(run[k].token.text[0])
+		}
+		states := model.viterbi(runes)
+		wordStart := 0
+		for k, state := range states {
+			if state != stateE && state != stateS && k < len(states)-1 {
+				continue
+			}
+			if k == wordStart {
+				output = append(output, run[k])
+			} else {
+				words := make([]Text, 0, k-wordStart+1)
+				for _, s := range run[wordStart : k+1] {
+					words = append(words, s.token.text[0])
+				}
+				output = append(output, Segment{
+					start: run[wordStart].start,
+					end:   run[k].end,
+					token: &Token{text: words, frequency: 1, distance: 32, pos: "x"},
+				})
+			}
+			wordStart = k + 1
+		}
+		i = j
+	}
+	return output
+}
+
+// 分词是否为单个汉字
+func isSingleHan(token *Token) bool {
+	if len(token.text) != 1 {
+		return false
+	}
+	r, size := utf8.DecodeRune(token.text[0])
+	return size == len(token.text[0]) && unicode.Is(unicode.Han, r)
+}
+
+// 返回字元对应的汉字，有非汉字字元时返回false
+func hanRunes(text []Text) ([]rune, bool) {
+	runes := make([]rune, len(text))
+	for i, word := range text {
+		r, size := utf8.DecodeRune(word)
+		if size != len(word) || !unicode.Is(unicode.Han, r) {
+			return nil, false
+		}
+		runes[i] = r
+	}
+	return runes, true
+}
@@ -0,0 +1,99 @@
+package sego
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const hmmTestDict = `李明 100 nr
+李华 100 nr
+李强 100 nr
+张伟 100 nr
+王伟 100 nr
+刘伟 100 nr
+的 100 uj
+是 100 v
+`
+
+func loadHMMTestSegmenter(t *testing.T) *Segmenter {
+	seg := &Segmenter{}
+	if err := seg.LoadDictionaryFromReaders([]io.Reader{strings.NewReader(hmmTestDict)}); err != nil {
+		t.Fatal(err)
+	}
+	return seg
+}
+
+func TestSegmentHMM(t *testing.T) {
+	seg := loadHMMTestSegmenter(t)
+
+	// 李伟不在词典中，不用模型时拆成单字
+	expect(t, "李/x 伟/x 是/v ", SegmentsToString(seg.Segment([]byte("李伟是")), false))
+
+	segments := seg.SegmentHMM([]byte("李伟是"))
+	expect(t, "李伟/x 是/v ", SegmentsToString(segments, false))
+	expect(t, "2", len(segments))
+	expect(t, "0", segments[0].start)
+	expect(t, "6", segments[0].end)
+	expect(t, "6", segments[1].start)
+	expect(t, "9", segments[1].end)
+
+	// 词典中的词不受影响
+	expect(t, "李明/nr 是/v ", SegmentsToString(seg.SegmentHMM([]byte("李明是")), false))
+	expect(t, "hello/x  /x 李明/nr ", SegmentsToString(seg.SegmentHMM([]byte("Hello 李明")), false))
+}
+
+func TestHMMViterbi(t *testing.T) {
+	seg := loadHMMTestSegmenter(t)
+	model := seg.hmmModel()
+
+	for _, text := range []string{"李", "李伟", "的李伟是王强", "是是是"} {
+		states := model.viterbi([]rune(text))
+		expect(t, strconv.Itoa(len([]rune(text))), len(states))
+		// 状态序列须以词首或单字开始，以词尾或单字结束，词首后只能是词中或词尾
+		if states[0] != stateB && states[0] != stateS {
+			t.Errorf("%s: 无效的起始状态 %v", text, states)
+		}
+		if last := states[len(states)-1]; last != stateE && last != stateS {
+			t.Errorf("%s: 无效的结束状态 %v", text, states)
+		}
+		for i := 1; i < len(states); i++ {
+			inWord := states[i-1] == stateB || states[i-1] == stateM
+			if inWord != (states[i] == stateM || states[i] == stateE) {
+				t.Errorf("%s: 无效的状态转移 %v", text, states)
+			}
+		}
+	}
+
+	// 词典更换后重新训练
+	if err := seg.LoadDictionaryFromReaders([]io.Reader{strings.NewReader(hmmTestDict)}); err != nil {
+		t.Fatal(err)
+	}
+	if seg.hmmModel() == model {
+		t.Error("期待重新训练的模型")
+	}
+}
+
+func TestSegmentHMMConcurrent(t *testing.T) {
+	seg := loadHMMTestSegmenter(t)
+
+	// 并发分词共用同一个模型
+	var wg sync.WaitGroup
+	results := make([]string, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = SegmentsToString(seg.SegmentHMM([]byte("李伟是")), false)
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range results {
+		expect(t, "李伟/x 是/v ", result)
+	}
+	if seg.hmmModel() != seg.hmmModel() {
+		t.Error("期待同一词典只训练一次模型")
+	}
+}
@@ -157,6 +157,19 @@ func (seg *Segmenter) InternalSegment(bytes []byte, searchMode bool) []Segment {
 	return seg.internalSegment(bytes, searchMode)
 }
 
+// 对文本分词，并用隐马尔可夫模型把连续的单字合并成词典中没有的词，
+// 比如人名、品牌等新词
+//
+// 模型从词典的分词和词频训练，输出格式同Segment
+func (seg *Segmenter) SegmentHMM(bytes []byte) []Segment {
+	return seg.hmmModel().recognize(seg.internalSegment(bytes, false))
+}
+
+// 返回当前词典的未登录词识别模型，词典更换后即为新词典的模型
+func (seg *Segmenter) hmmModel() *hmmModel {
+	return seg.dict.hmmModel()
+}
+
 // 释放资源
 func (seg *Segmenter) Close() {
 	if seg.dict != nil {
@@ -76,10 +76,19 @@ func detectTokenType(term []byte) analysis.TokenType {
 }
 
 func SegmentsToTokenStream(field []byte, segs []Segment, searchMode bool) analysis.TokenStream {
+	output, _ := SegmentsToTaggedTokenStream(field, segs, searchMode)
+	return output
+}
+
+// 输出分词结果为bleve的token流，同时返回与之一一对应的词性标注
+//
+// 分词模式同SegmentsToTokenStream
+func SegmentsToTaggedTokenStream(field []byte, segs []Segment, searchMode bool) (analysis.TokenStream, []string) {
 	output := make(analysis.TokenStream, 0)
+	tags := make([]string, 0)
 	if searchMode {
 		for _, seg := range segs {
-			output = segToTokenStream(output, field, &seg, 0)
+			output, tags = segToTokenStream(output, tags, field, &seg, 0)
 		}
 		for pos, token := range output {
 			token.Position = pos + 1
@@ -95,12 +104,13 @@ func SegmentsToTokenStream(field []byte, segs []Segment, searchMode bool) analys
 				Type:     detectTokenType(field[seg.start:seg.end]),
 			}
 			output = append(output, &token)
+			tags = append(tags, seg.token.pos)
 		}
 	}
-	return output
+	return output, tags
 }
 
-func segToTokenStream(output analysis.TokenStream, field []byte, seg *Segment, offset int) analysis.TokenStream {
+func segToTokenStream(output analysis.TokenStream, tags []string, field []byte, seg *Segment, offset int) (analysis.TokenStream, []string) {
 	hasOnlyTerminalToken := true
 	for _, s := range seg.token.segments {
 		if len(s.token.segments) > 1 {
@@ -110,7 +120,7 @@ func segToTokenStream(output analysis.TokenStream, field []byte, seg *Segment, o
 
 	if !hasOnlyTerminalToken {
 		for _, s := range seg.token.segments {
-			output = segToTokenStream(output, field, s, offset+seg.start)
+			output, tags = segToTokenStream(output, tags, field, s, offset+seg.start)
 		}
 	}
 
@@ -124,7 +134,7 @@ func segToTokenStream(output analysis.TokenStream, field []byte, seg *Segment, o
 	}
 
 	if len(token.Term) == 1 && unicode.IsSpace(rune(token.Term[0])) {
-		return output
+		return output, tags
 	}
 
 	/*if len(output) > 0 {
@@ -137,7 +147,8 @@ func segToTokenStream(output analysis.TokenStream, field []byte, seg *Segment, o
 	}*/
 
 	output = append(output, &token)
-	return output
+	tags = append(tags, seg.token.pos)
+	return output, tags
 }
 
 // 输出分词结果到一个字符串slice
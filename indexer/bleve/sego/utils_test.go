@@ -2,6 +2,8 @@ package sego
 
 import (
 	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/issue9/assert"
@@ -142,3 +144,27 @@ func Test_Token_Split(t *testing.T) {
 		}
 	}
 }
+
+func TestSegmentsToTaggedTokenStream(t *testing.T) {
+	var seg Segmenter
+	dict := "中国 100 ns\n人口 80 n\n的 100 uj\n中华人民共和国 50 ns\n中华 30 nz\n人民 90 n\n共和国 40 ns\n"
+	if err := seg.LoadDictionaryFromReaders([]io.Reader{strings.NewReader(dict)}); err != nil {
+		t.Fatal(err)
+	}
+
+	text := []byte("中国的人口")
+	tokens, tags := SegmentsToTaggedTokenStream(text, seg.Segment(text), false)
+	expect(t, "3", len(tokens))
+	expect(t, "[ns uj n]", tags)
+	expect(t, "的", string(tokens[1].Term))
+
+	text = []byte("中华人民共和国")
+	tokens, tags = SegmentsToTaggedTokenStream(text, seg.Segment(text), true)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = string(token.Term)
+	}
+	expect(t, "[中华 人民 共和国 中华人民共和国]", terms)
+	expect(t, "[nz n ns ns]", tags)
+	expect(t, fmt.Sprint(len(tokens)), len(SegmentsToTokenStream(text, seg.Segment(text), true)))
+}
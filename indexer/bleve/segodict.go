@@ -0,0 +1,298 @@
+package bleve
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer/bleve/sego"
+)
+
+// segoDictionary is a set of dictionaries shared by the sego tokenizers using
+// them. Its segmenter is swapped as a whole on reload, so that tokenizing goes
+// on with the previous one meanwhile.
+type segoDictionary struct {
+	files      []string
+	useDefault bool
+	// cacheDir holds the binary form of the dictionaries, none if empty
+	cacheDir string
+
+	// reloadLock serializes reloads
+	reloadLock sync.Mutex
+	segmenter  atomic.Value // *sego.Segmenter
+	// words maps the words of the user dictionaries to their entry
+	words map[string]string
+}
+
+var (
+	segoDictionariesLock sync.Mutex
+	segoDictionaries     = make(map[string]*segoDictionary)
+	segoCacheDir         string
+)
+
+// setSegoCacheDir sets the directory caching the binary form of the
+// dictionaries loaded from now on, none if empty
+func setSegoCacheDir(dir string) {
+	segoDictionariesLock.Lock()
+	segoCacheDir = dir
+	segoDictionariesLock.Unlock()
+}
+
+// segoDictionaryKey identifies a set of dictionaries
+func segoDictionaryKey(files []string, useDefault bool) string {
+	return strings.Join(files, ",") + "|" + strconv.FormatBool(useDefault)
+}
+
+// getSegoDictionary returns the shared set of dictionaries, loading it on first use
+func getSegoDictionary(files []string, useDefault bool) (*segoDictionary, error) {
+	segoDictionariesLock.Lock()
+	defer segoDictionariesLock.Unlock()
+
+	key := segoDictionaryKey(files, useDefault)
+	if dict, ok := segoDictionaries[key]; ok {
+		return dict, nil
+	}
+	dict := &segoDictionary{files: files, useDefault: useDefault, cacheDir: segoCacheDir}
+	if _, err := dict.reload(); err != nil {
+		return nil, err
+	}
+	segoDictionaries[key] = dict
+	return dict, nil
+}
+
+// lookupSegoDictionary returns the shared set of dictionaries if loaded, nil otherwise
+func lookupSegoDictionary(files []string, useDefault bool) *segoDictionary {
+	segoDictionariesLock.Lock()
+	defer segoDictionariesLock.Unlock()
+	return segoDictionaries[segoDictionaryKey(files, useDefault)]
+}
+
+// get returns the current segmenter
+func (d *segoDictionary) get() *sego.Segmenter {
+	return d.segmenter.Load().(*sego.Segmenter)
+}
+
+// reload loads the dictionaries again and returns the words of the user
+// dictionaries which were added, removed or changed since the previous load
+func (d *segoDictionary) reload() ([]string, error) {
+	d.reloadLock.Lock()
+	defer d.reloadLock.Unlock()
+
+	contents := make([][]byte, 0)
+	words := make(map[string]string)
+	for _, file := range d.files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+		dictionaryWords(content, words)
+	}
+	if d.useDefault {
+		content, err := dicts.ReadFile(defaultDict)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+	if len(contents) == 0 {
+		return nil, fmt.Errorf("no sego dictionary")
+	}
+
+	seg, err := loadSegmenter(d.files, contents, d.cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make([]string, 0)
+	if d.words != nil {
+		for word, entry := range words {
+			if d.words[word] != entry {
+				changed = append(changed, word)
+			}
+		}
+		for word := range d.words {
+			if _, ok := words[word]; !ok {
+				changed = append(changed, word)
+			}
+		}
+	}
+	d.words = words
+	d.segmenter.Store(seg)
+	return changed, nil
+}
+
+// loadSegmenter builds a segmenter from the contents of the dictionary files.
+// When cacheDir is set, the dictionary is loaded from its binary form, saved
+// there the first time, named after a hash of the contents so that edited
+// dictionaries are parsed again.
+func loadSegmenter(files []string, contents [][]byte, cacheDir string) (*sego.Segmenter, error) {
+	seg := &sego.Segmenter{}
+	cacheFile := ""
+	if cacheDir != "" {
+		hash := sha256.New()
+		for _, content := range contents {
+			fmt.Fprintf(hash, "%d\n", len(content))
+			hash.Write(content)
+		}
+		cacheFile = filepath.Join(cacheDir, "sego-"+hex.EncodeToString(hash.Sum(nil))+".bin")
+
+		if file, err := os.Open(cacheFile); err == nil {
+			err = seg.LoadBinaryDictionary(file)
+			file.Close()
+			if err == nil {
+				log.Printf("Loaded sego dictionaries %v from %s", files, cacheFile)
+				return seg, nil
+			}
+			log.Printf("Ignore sego dictionary cache error %v,%v", err, cacheFile)
+		}
+	}
+
+	readers := make([]io.Reader, len(contents))
+	for n, content := range contents {
+		readers[n] = bytes.NewReader(content)
+	}
+	log.Printf("Loading sego dictionaries %v", files)
+	if err := seg.LoadDictionaryFromReaders(readers); err != nil {
+		return nil, err
+	}
+
+	if cacheFile != "" {
+		if err := saveSegmenter(seg, cacheFile); err != nil {
+			log.Printf("Ignore sego dictionary cache error %v,%v", err, cacheFile)
+		}
+	}
+	return seg, nil
+}
+
+// saveSegmenter writes the binary form of the dictionary of seg to file,
+// through a temporary file so that concurrent loads never see it partial
+func saveSegmenter(seg *sego.Segmenter, file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := seg.SaveDictionary(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// dictionaryWords adds the words of a dictionary to words, mapped to their
+// entry. The first entry of a word wins, as when segmenting.
+func dictionaryWords(content []byte, words map[string]string) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if _, ok := words[fields[0]]; !ok {
+			words[fields[0]] = strings.Join(fields[1:], " ")
+		}
+	}
+}
+
+// Dictionaries returns the user dictionaries of the sego tokenizer
+func (i *bleveIndexer) Dictionaries() []string {
+	return i.segoDicts
+}
+
+// ReloadDictionaries reloads the dictionaries of the sego tokenizer and
+// returns the words of the user dictionaries which changed
+func (i *bleveIndexer) ReloadDictionaries() ([]string, error) {
+	dict := lookupSegoDictionary(i.segoDicts, true)
+	if dict == nil {
+		// not loaded yet, it will be up to date
+		return nil, nil
+	}
+	return dict.reload()
+}
+
+// Reindex indexes again the records whose title or body holds one of words,
+// it returns their number
+func (i *bleveIndexer) Reindex(words []string) (int, error) {
+	const pageSize = 1000
+	if len(words) == 0 {
+		return 0, nil
+	}
+
+	paths := make([]string, 0)
+	for from := 0; ; from += pageSize {
+		request := bleve.NewSearchRequestOptions(affectedQuery(words), pageSize, from, false)
+		request.Fields = []string{"Title", "Body"}
+		request.SortBy([]string{"_id"})
+		result, err := i.bleve.Search(request)
+		if err != nil {
+			return 0, err
+		}
+		// the query also matches the records holding parts of the words only
+		for _, match := range result.Hits {
+			title, _ := match.Fields["Title"].(string)
+			body, _ := match.Fields["Body"].(string)
+			for _, word := range words {
+				if strings.Contains(title, word) || strings.Contains(body, word) {
+					paths = append(paths, match.ID)
+					break
+				}
+			}
+		}
+		if len(result.Hits) < pageSize {
+			break
+		}
+	}
+
+	for _, path := range paths {
+		doc, err := i.bleve.Document(path)
+		if err != nil || doc == nil {
+			continue
+		}
+		rec := i.Record(path).(*Record)
+		rec.load(doc)
+		i.index(rec)
+	}
+	return len(paths), nil
+}
+
+// affectedQuery matches the records whose title or body may hold one of
+// words, as segmented before the dictionaries changed: the removed or changed
+// words are terms of their records, the added ones were cut in the smaller
+// words their new segmentation gives or else in single characters
+func affectedQuery(words []string) query.Query {
+	disjuncts := make([]query.Query, 0, 6*len(words))
+	for _, word := range words {
+		for _, field := range []string{"Title", "Body"} {
+			term := bleve.NewTermQuery(strings.ToLower(word))
+			term.SetField(field)
+			parts := bleve.NewMatchQuery(word)
+			parts.SetField(field)
+			characters := bleve.NewMatchPhraseQuery(word)
+			characters.SetField(field)
+			characters.Analyzer = standard.Name
+			disjuncts = append(disjuncts, term, parts, characters)
+		}
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
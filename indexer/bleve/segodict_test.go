@@ -0,0 +1,78 @@
+package bleve
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+func TestReindex(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "user.txt")
+	if err := os.WriteFile(file, []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+	i := newTestIndexer(t, indexer.Config{Analyzer: "sego", SegoDicts: []string{file}})
+	indexTestRecord(i, "/caddy.md", "我们用caddy搜索网站", time.Now())
+	indexTestRecord(i, "/name.md", "李伟强是工程师", time.Now())
+	indexTestRecord(i, "/other.md", "北京欢迎你", time.Now())
+
+	search := func(q string) []string {
+		resp := i.Search(indexer.SearchRequest{Query: q, Size: 10})
+		if resp.Err != nil {
+			t.Fatal(resp.Err)
+		}
+		var paths []string
+		for _, hit := range resp.Hits {
+			paths = append(paths, hit.Path())
+		}
+		return paths
+	}
+	reload := func(content string) []string {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		words, err := i.ReloadDictionaries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(words)
+		return words
+	}
+
+	tests := []struct {
+		name    string
+		dict    string
+		changed []string
+		// the words are the terms of their records once reindexed
+		terms []string
+	}{
+		// the records held the words in smaller words or in single characters
+		{"added", "caddy搜索 1000 n\n李伟强 1000 nr\n", []string{"caddy搜索", "李伟强"}, []string{"caddy搜索", "李伟强"}},
+		// the records held the words as terms
+		{"removed", "", []string{"caddy搜索", "李伟强"}, nil},
+	}
+	for _, test := range tests {
+		changed := reload(test.dict)
+		if !reflect.DeepEqual(changed, test.changed) {
+			t.Fatalf("%v: got changed words %v, want %v", test.name, changed, test.changed)
+		}
+		// the record holding none of the words is left alone
+		n, err := i.Reindex(changed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("%v: reindexed %d records, want 2", test.name, n)
+		}
+		for _, term := range test.terms {
+			if got := search("Body:" + term); len(got) != 1 {
+				t.Errorf("%v: %q got %v, want a record", test.name, term, got)
+			}
+		}
+	}
+}
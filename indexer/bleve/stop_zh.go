@@ -0,0 +1,62 @@
+package bleve
+
+import (
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/token/stop"
+	"github.com/blevesearch/bleve/v2/registry"
+)
+
+// StopZhName is the name of the token filter removing the Chinese stop words
+const StopZhName = "stop_zh"
+
+// StopWordsZhName is the name of the token map of the Chinese stop words
+const StopWordsZhName = "stop_words_zh"
+
+// ChineseStopWords are the particles, pronouns, prepositions, conjunctions
+// and other function words of Chinese, in Simplified and Traditional script
+// so that they are removed before and after a jianfan conversion
+var ChineseStopWords = []byte(`# particles
+的 地 得 之 了 着 著 过 過 所
+吗 嗎 呢 吧 啊 呀 哦 嘛 呗 唄 啦 哇 么 麼 嗯 哟 喲 矣 焉 哉 乎 耶
+
+# pronouns and determiners
+我 你 您 他 她 它 我们 我們 你们 你們 他们 他們 她们 她們 它们 它們 咱们 咱們
+自己 其 此 彼 该 該 各 每 某 本
+这 這 那 这个 這個 那个 那個 这些 這些 那些 这样 這樣 那样 那樣 这里 這裡 那里 那裡
+一些 有些 其中 其他 其它
+
+# prepositions
+在 于 於 以 为 為 对 對 从 從 向 往 把 被 让 讓 给 給 跟 比 由 自 按 据 據 关于 關於 对于 對於
+
+# conjunctions
+和 与 與 及 以及 或 或者 而 而且 并 並 并且 並且 但 但是 然而 可是 不过 不過
+因为 因為 所以 因此 于是 於是 然后 然後 如果 虽然 雖然 即使 只要 只有 还是 還是
+
+# adverbs and verbs
+是 有 也 都 就 又 还 還 再 才 已 已经 已經 曾 曾经 曾經 将 將 很 太 更 最 非常
+会 會 能 可 可以 要 应 應 应该 應該
+
+# numerals and classifiers
+一 个 個 种 種 等 等等
+`)
+
+// StopWordsZhConstructor loads ChineseStopWords
+func StopWordsZhConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenMap, error) {
+	rv := analysis.NewTokenMap()
+	err := rv.LoadBytes(ChineseStopWords)
+	return rv, err
+}
+
+// StopZhFilterConstructor creates the token filter removing ChineseStopWords
+func StopZhFilterConstructor(config map[string]interface{}, cache *registry.Cache) (analysis.TokenFilter, error) {
+	tokenMap, err := cache.TokenMapNamed(StopWordsZhName)
+	if err != nil {
+		return nil, err
+	}
+	return stop.NewStopTokensFilter(tokenMap), nil
+}
+
+func init() {
+	registry.RegisterTokenMap(StopWordsZhName, StopWordsZhConstructor)
+	registry.RegisterTokenFilter(StopZhName, StopZhFilterConstructor)
+}
@@ -0,0 +1,53 @@
+package bleve
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/registry"
+)
+
+func TestStopWordsZh(t *testing.T) {
+	tokenMap, err := StopWordsZhConstructor(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for word, want := range map[string]bool{
+		"的":         true,
+		"嗎":         true,
+		"这个":        true,
+		"這個":        true,
+		"以及":        true,
+		"等等":        true,
+		"北京":        false,
+		"搜索":        false,
+		"#":         false,
+		"particles": false,
+	} {
+		if got := tokenMap[word]; got != want {
+			t.Errorf("%q: got %v, want %v", word, got, want)
+		}
+	}
+}
+
+func TestStopZhFilter(t *testing.T) {
+	filter, err := registry.NewCache().TokenFilterNamed(StopZhName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := analysis.TokenStream{
+		{Term: []byte("我们"), Position: 1},
+		{Term: []byte("的"), Position: 2},
+		{Term: []byte("搜索引擎"), Position: 3},
+		{Term: []byte("這個"), Position: 4},
+		{Term: []byte("caddy"), Position: 5},
+	}
+	var got []string
+	for _, token := range filter.Filter(input) {
+		got = append(got, string(token.Term))
+	}
+	if want := []string{"搜索引擎", "caddy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,356 @@
+package bleve
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	bleve "github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	index "github.com/blevesearch/bleve_index_api"
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+// suggestFields are the fields whose terms complete a query
+var suggestFields = []string{"Title", "Body"}
+
+// Suggest completes the last word of q with the most frequent terms of the index
+// and lists the titles of the documents holding such a term in their title
+func (i *bleveIndexer) Suggest(q string, size int) (suggestions indexer.Suggestions, err error) {
+	q = strings.ToLower(strings.TrimLeftFunc(q, unicode.IsSpace))
+	if q == "" || unicode.IsSpace(lastRune(q)) {
+		return
+	}
+
+	words, prefix := splitLastWord(q)
+	head := words
+	terms, err := i.termsWithPrefix(prefix, size)
+	if err != nil {
+		return
+	}
+	// Chinese is written without spaces, try the shorter tails of the word
+	// until one of them starts a term, "我想去北" completing as "我想去北京"
+	for len(terms) == 0 && utf8.RuneCountInString(prefix) > 1 && isIdeograph(lastRune(prefix)) {
+		_, n := utf8.DecodeRuneInString(prefix)
+		head, prefix = head+prefix[:n], prefix[n:]
+		terms, err = i.termsWithPrefix(prefix, size)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, term := range terms {
+		suggestions.Terms = append(suggestions.Terms, head+term)
+	}
+	if len(terms) > 0 {
+		suggestions.Titles, err = i.titlesWithPrefix(words, prefix, size)
+	}
+	return
+}
+
+// suggestScanMax is the number of terms of each field ranked by termsWithPrefix,
+// the dictionaries are not walked further for the short prefixes
+var suggestScanMax = 1000
+
+// termsWithPrefix returns the size most frequent terms starting with prefix,
+// among the first suggestScanMax ones of each field
+func (i *bleveIndexer) termsWithPrefix(prefix string, size int) ([]string, error) {
+	counts := make(map[string]uint64)
+	for _, field := range suggestFields {
+		dict, err := i.bleve.FieldDictPrefix(field, []byte(prefix))
+		if err != nil {
+			return nil, err
+		}
+		entry, err := dict.Next()
+		for scanned := 0; entry != nil && err == nil && scanned < suggestScanMax; scanned++ {
+			counts[entry.Term] += entry.Count
+			entry, err = dict.Next()
+		}
+		dict.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	terms := make([]string, 0, len(counts))
+	for term := range counts {
+		terms = append(terms, term)
+	}
+	sort.Slice(terms, func(a, b int) bool {
+		if counts[terms[a]] != counts[terms[b]] {
+			return counts[terms[a]] > counts[terms[b]]
+		}
+		return terms[a] < terms[b]
+	})
+	if len(terms) > size {
+		terms = terms[:size]
+	}
+	return terms, nil
+}
+
+// titlesWithPrefix returns the titles matching words and holding a term starting with prefix
+func (i *bleveIndexer) titlesWithPrefix(words, prefix string, size int) ([]string, error) {
+	prefixQuery := bleve.NewPrefixQuery(prefix)
+	prefixQuery.SetField("Title")
+	var q query.Query = prefixQuery
+	if strings.TrimSpace(words) != "" {
+		wordsQuery := bleve.NewMatchQuery(words)
+		wordsQuery.SetField("Title")
+		wordsQuery.SetOperator(query.MatchQueryOperatorAnd)
+		q = bleve.NewConjunctionQuery(wordsQuery, prefixQuery)
+	}
+
+	request := bleve.NewSearchRequestOptions(q, size, 0, false)
+	request.Fields = []string{"Title"}
+	result, err := i.bleve.Search(request)
+	if err != nil {
+		return nil, err
+	}
+
+	titles := make([]string, 0, len(result.Hits))
+	seen := make(map[string]bool)
+	for _, match := range result.Hits {
+		title, _ := match.Fields["Title"].(string)
+		if title == "" || seen[title] {
+			continue
+		}
+		seen[title] = true
+		titles = append(titles, title)
+	}
+	return titles, nil
+}
+
+// correct spells the words of q which are not in the index like the closest
+// frequent terms of the index, it returns "" when there is nothing to correct
+func (i *bleveIndexer) correct(q string) (string, error) {
+	words := strings.Fields(q)
+	unknown := make(map[string]*correction)
+	for _, word := range words {
+		term := strings.ToLower(strings.TrimLeft(word, "+-"))
+		// leave the query syntax and the ideographs alone
+		if term == "" || strings.ContainsAny(term, `:"*?~^/\()[]{}`) || strings.IndexFunc(term, isIdeograph) >= 0 {
+			continue
+		}
+		if _, ok := unknown[term]; ok {
+			continue
+		}
+		terms, err := i.fieldTerms(term)
+		if err != nil {
+			return "", err
+		}
+		if terms != nil {
+			unknown[term] = &correction{terms: terms, distance: maxEditDistance(term) + 1}
+		}
+	}
+	if len(unknown) == 0 {
+		return "", nil
+	}
+
+	idx, err := i.bleve.Advanced()
+	if err != nil {
+		return "", err
+	}
+	reader, err := idx.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	fuzzy, isFuzzy := reader.(fuzzyDictReader)
+	for _, field := range suggestFields {
+		if isFuzzy {
+			// the automaton walks the terms within reach of the word only
+			for _, c := range unknown {
+				term := c.terms[field]
+				dict, err := fuzzy.FieldDictFuzzy(field, term, maxEditDistance(term), "")
+				if err != nil {
+					return "", err
+				}
+				err = visitDict(dict, 0, func(entry *index.DictEntry) {
+					c.consider(term, entry.Term, entry.Count)
+				})
+				if err != nil {
+					return "", err
+				}
+			}
+			continue
+		}
+		dict, err := reader.FieldDict(field)
+		if err != nil {
+			return "", err
+		}
+		err = visitDict(dict, correctScanMax, func(entry *index.DictEntry) {
+			for _, c := range unknown {
+				c.consider(c.terms[field], entry.Term, entry.Count)
+			}
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	corrected := false
+	for n, word := range words {
+		sign := word[:len(word)-len(strings.TrimLeft(word, "+-"))]
+		if c, ok := unknown[strings.ToLower(word[len(sign):])]; ok && c.term != "" {
+			words[n] = sign + c.term
+			corrected = true
+		}
+	}
+	if !corrected {
+		return "", nil
+	}
+	return strings.Join(words, " "), nil
+}
+
+// fuzzyDictReader is implemented by the index readers walking the terms within
+// an edit distance of a term with a Levenshtein automaton, like scorch's
+type fuzzyDictReader interface {
+	FieldDictFuzzy(field string, term string, fuzziness int, prefix string) (index.FieldDict, error)
+}
+
+// correctScanMax is the number of terms of each field compared to the
+// misspelled words when the index has no fuzzy dictionaries
+var correctScanMax = 100000
+
+// visitDict calls visit with the first max entries of dict, all of them if max
+// is 0, and closes it
+func visitDict(dict index.FieldDict, max int, visit func(*index.DictEntry)) error {
+	defer dict.Close()
+	entry, err := dict.Next()
+	for n := 0; entry != nil && err == nil && (max == 0 || n < max); n++ {
+		visit(entry)
+		entry, err = dict.Next()
+	}
+	return err
+}
+
+// fieldTerms returns the term indexed for word in each suggest field, as cut by
+// the analyzer of the field. It returns nil when there is nothing to correct:
+// an analyzer drops the word (e.g. a stop word) or splits it, or one of the
+// terms is in the index (e.g. the stem of an inflected word).
+func (i *bleveIndexer) fieldTerms(word string) (map[string]string, error) {
+	indexMapping := i.bleve.Mapping()
+	terms := make(map[string]string, len(suggestFields))
+	for _, field := range suggestFields {
+		term := word
+		if analyzer := indexMapping.AnalyzerNamed(indexMapping.AnalyzerNameForPath(field)); analyzer != nil {
+			tokens := analyzer.Analyze([]byte(word))
+			if len(tokens) != 1 {
+				return nil, nil
+			}
+			term = string(tokens[0].Term)
+		}
+		known, err := i.hasTerm(field, term)
+		if err != nil || known {
+			return nil, err
+		}
+		terms[field] = term
+	}
+	return terms, nil
+}
+
+// hasTerm reports if term is in the dictionary of field
+func (i *bleveIndexer) hasTerm(field, term string) (bool, error) {
+	dict, err := i.bleve.FieldDictPrefix(field, []byte(term))
+	if err != nil {
+		return false, err
+	}
+	entry, err := dict.Next()
+	dict.Close()
+	if err != nil {
+		return false, err
+	}
+	return entry != nil && entry.Term == term, nil
+}
+
+// correction is the best replacement found so far for a misspelled word
+type correction struct {
+	// terms are the analyzed word in each field
+	terms    map[string]string
+	term     string
+	distance int
+	count    uint64
+}
+
+// consider replaces the correction by term if it is closer to word, or as
+// close but more frequent
+func (c *correction) consider(word, term string, count uint64) {
+	max := maxEditDistance(word)
+	if d := utf8.RuneCountInString(term) - utf8.RuneCountInString(word); d > max || -d > max {
+		return
+	}
+	distance, exceeded := editDistance(word, term, max)
+	if exceeded {
+		return
+	}
+	if distance < c.distance || (distance == c.distance && count > c.count) {
+		c.term = term
+		c.distance = distance
+		c.count = count
+	}
+}
+
+// editDistance returns the Levenshtein distance between a and b in runes, and
+// whether it exceeds max, in which case the distance is not exact
+func editDistance(a, b string, max int) (int, bool) {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for y := range row {
+		row[y] = y
+	}
+	for x := 1; x <= len(ra); x++ {
+		diagonal := row[0]
+		row[0] = x
+		rowMin := row[0]
+		for y := 1; y <= len(rb); y++ {
+			distance := diagonal
+			if ra[x-1] != rb[y-1] {
+				distance++
+			}
+			if row[y]+1 < distance {
+				distance = row[y] + 1
+			}
+			if row[y-1]+1 < distance {
+				distance = row[y-1] + 1
+			}
+			diagonal, row[y] = row[y], distance
+			if distance < rowMin {
+				rowMin = distance
+			}
+		}
+		if rowMin > max {
+			return rowMin, true
+		}
+	}
+	return row[len(rb)], row[len(rb)] > max
+}
+
+// maxEditDistance is the number of typos tolerated in word
+func maxEditDistance(word string) int {
+	if utf8.RuneCountInString(word) <= 4 {
+		return 1
+	}
+	return 2
+}
+
+// isIdeograph reports if r is a CJK ideograph
+func isIdeograph(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// splitLastWord splits q before its last space separated word
+func splitLastWord(q string) (head, word string) {
+	n := strings.LastIndexFunc(q, unicode.IsSpace)
+	if n < 0 {
+		return "", q
+	}
+	_, size := utf8.DecodeRuneInString(q[n:])
+	return q[:n+size], q[n+size:]
+}
+
+// lastRune returns the last rune of s
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
@@ -0,0 +1,226 @@
+package bleve
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+func TestTermsWithPrefix(t *testing.T) {
+	i := newTestIndexer(t, indexer.Config{})
+	indexTestRecord(i, "/a.md", "search searching searching seal", time.Now())
+	indexTestRecord(i, "/b.md", "searching seat", time.Now())
+
+	tests := []struct {
+		prefix  string
+		size    int
+		scanMax int
+		want    []string
+	}{
+		{"sea", 10, 1000, []string{"searching", "seal", "search", "seat"}},
+		{"sea", 2, 1000, []string{"searching", "seal"}},
+		{"search", 10, 1000, []string{"searching", "search"}},
+		{"x", 10, 1000, nil},
+		// the terms are walked in lexical order
+		{"sea", 10, 2, []string{"seal", "search"}},
+	}
+	defer func(scanMax int) { suggestScanMax = scanMax }(suggestScanMax)
+	for _, test := range tests {
+		suggestScanMax = test.scanMax
+		got, err := i.termsWithPrefix(test.prefix, test.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, " ") != strings.Join(test.want, " ") {
+			t.Errorf("termsWithPrefix(%q, %d) with %d scanned = %v, want %v", test.prefix, test.size, test.scanMax, got, test.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		max      int
+		distance int
+		exceeded bool
+	}{
+		{"search", "search", 2, 0, false},
+		{"serch", "search", 2, 1, false},
+		{"saerch", "search", 2, 2, false},
+		{"", "ab", 2, 2, false},
+		{"abc", "", 2, 3, true},
+		{"kitten", "sitting", 2, 3, true},
+		// the distance counts runes, not bytes
+		{"cafe", "café", 1, 1, false},
+		{"北京", "南京", 1, 1, false},
+		{"北京", "北京市", 1, 1, false},
+	}
+	for _, test := range tests {
+		distance, exceeded := editDistance(test.a, test.b, test.max)
+		if exceeded != test.exceeded || (!exceeded && distance != test.distance) {
+			t.Errorf("editDistance(%q, %q, %d) = %d, %v, want %d, %v", test.a, test.b, test.max, distance, exceeded, test.distance, test.exceeded)
+		}
+	}
+}
+
+func TestCorrect(t *testing.T) {
+	i := newScorchTestIndexer(t, indexer.Config{})
+	indexTestRecord(i, "/a.md", "search engine café résumé", time.Now())
+	indexTestRecord(i, "/b.md", "search seats tie", time.Now())
+
+	// the terms close to the words are walked with scorch's fuzzy dictionaries
+	idx, err := i.bleve.Advanced()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := idx.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, isFuzzy := reader.(fuzzyDictReader)
+	reader.Close()
+	if !isFuzzy {
+		t.Fatalf("got a %T reader, want a fuzzy one", reader)
+	}
+
+	tests := []struct {
+		q    string
+		want string
+	}{
+		{"search", ""},
+		{"serch engne", "search engine"},
+		{"+Serch -engine", "+search -engine"},
+		{"seach", "search"},
+		{"cafe", "café"},
+		{"resume", "résumé"},
+		{"xyzzy", ""},
+		{"title:serch", ""},
+		{"北京", ""},
+		// the stop words are not indexed, nor corrected to the terms close to them
+		{"the xyzzy", ""},
+		{"the serch", "the search"},
+	}
+	for _, test := range tests {
+		got, err := i.correct(test.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("correct(%q) = %q, want %q", test.q, got, test.want)
+		}
+	}
+}
+
+func TestCorrectStemmed(t *testing.T) {
+	i := newScorchTestIndexer(t, indexer.Config{Analyzer: "en"})
+	indexTestRecord(i, "/a.md", "searching engines", time.Now())
+
+	tests := []struct {
+		q    string
+		want string
+	}{
+		// the inflected words are known by their stem
+		{"searched xyzzy", ""},
+		{"engine", ""},
+		// the misspelled words are corrected to the closest stem
+		{"serching", "search"},
+	}
+	for _, test := range tests {
+		got, err := i.correct(test.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("correct(%q) = %q, want %q", test.q, got, test.want)
+		}
+	}
+}
+
+func TestCorrectScan(t *testing.T) {
+	// the in-memory index has no fuzzy dictionaries, its terms are scanned
+	i := newTestIndexer(t, indexer.Config{})
+	indexTestRecord(i, "/a.md", "search engine café résumé", time.Now())
+	indexTestRecord(i, "/b.md", "search seats", time.Now())
+
+	tests := []struct {
+		q       string
+		scanMax int
+		want    string
+	}{
+		{"serch engne", 100000, "search engine"},
+		{"cafe", 100000, "café"},
+		{"xyzzy", 100000, ""},
+		// the terms are walked in lexical order, search comes after café
+		{"serch", 1, ""},
+	}
+	defer func(scanMax int) { correctScanMax = scanMax }(correctScanMax)
+	for _, test := range tests {
+		correctScanMax = test.scanMax
+		got, err := i.correct(test.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("correct(%q) with %d scanned = %q, want %q", test.q, test.scanMax, got, test.want)
+		}
+	}
+}
+
+func TestSuggestSego(t *testing.T) {
+	i := newTestIndexer(t, indexer.Config{Analyzer: "sego"})
+	for path, doc := range map[string][2]string{
+		"/trip.md":       {"北京旅游指南", "我想去北京旅游"},
+		"/university.md": {"北京大学", "北京大学的图书馆"},
+		"/caddy.md":      {"Caddy", "caddy server"},
+	} {
+		rec := i.Record(path).(*Record)
+		rec.SetTitle(doc[0])
+		rec.SetBody([]byte(doc[1]))
+		rec.SetModified(time.Now())
+		i.Index(rec)
+	}
+
+	tests := []struct {
+		name   string
+		q      string
+		first  string
+		titles []string
+	}{
+		// the prefix starts no term, its shorter tails are tried
+		{"tail of a sentence", "我想去北", "我想去北京", []string{"北京大学", "北京旅游指南"}},
+		{"prefix of a word", "北京大", "北京大学", []string{"北京大学"}},
+		// the titles hold the previous words too
+		{"after a word", "旅游 北", "旅游 北京", []string{"北京旅游指南"}},
+		{"latin", "Ca", "caddy", []string{"Caddy"}},
+		{"unknown", "上海", "", nil},
+		{"after a space", "我想去北 ", "", nil},
+		{"empty", "", "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := i.Suggest(test.q, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.first == "" {
+				if len(got.Terms) > 0 {
+					t.Errorf("got terms %v, want none", got.Terms)
+				}
+			} else if len(got.Terms) == 0 || got.Terms[0] != test.first {
+				t.Errorf("got terms %v, want %q first", got.Terms, test.first)
+			}
+			for _, term := range got.Terms {
+				if !strings.HasPrefix(term, strings.ToLower(test.q)) {
+					t.Errorf("got term %q, not completing %q", term, test.q)
+				}
+			}
+			sort.Strings(got.Titles)
+			if strings.Join(got.Titles, " ") != strings.Join(test.titles, " ") {
+				t.Errorf("got titles %v, want %v", got.Titles, test.titles)
+			}
+		})
+	}
+}
@@ -8,14 +8,191 @@ import (
 // Handler ...
 type Handler interface {
 	Record(string) Record
-	Search(string, int, int) []Record
+	Search(SearchRequest) SearchResponse
 	Index(Record)
+	Delete(string) error
+	DeletePrefix(string) error
+	FileBacked() (map[string]string, error)
+	Suggest(string, int) (Suggestions, error)
+}
+
+// DictionaryReloader is implemented by the handlers whose analysis relies on
+// dictionaries which can be reloaded while running
+type DictionaryReloader interface {
+	// Dictionaries returns the dictionary files
+	Dictionaries() []string
+	// ReloadDictionaries reloads the dictionaries and returns the words which
+	// were added, removed or changed
+	ReloadDictionaries() ([]string, error)
+	// Reindex indexes again the records holding any of words and returns their number
+	Reindex(words []string) (int, error)
 }
 
 // Config ...
 type Config struct {
 	DbName         string
 	IndexDirectory string
+	// Analyzer is the default analyzer of the text fields
+	Analyzer string
+	// Languages are the languages whose documents also get their body indexed
+	// with the analyzer of the language
+	Languages []string
+	// CustomAnalyzers, TokenFilters and TokenMaps define analysis components
+	// by name, usable as Analyzer, in FieldAnalyzers and by each other
+	CustomAnalyzers map[string]CustomAnalyzer
+	TokenFilters    map[string]Component
+	TokenMaps       map[string][]string
+	// FieldAnalyzers sets the analyzer of some fields, e.g. Title
+	FieldAnalyzers map[string]string
+	// SegoDicts are user dictionaries of the sego tokenizer, whose words take
+	// priority over those of its default dictionary
+	SegoDicts []string
+	// SegoCacheDir holds the binary form of the sego dictionaries, loaded
+	// much faster than the text ones, none if empty
+	SegoCacheDir string
+	// SegoHMM makes the sego tokenizer recognize the words missing from its
+	// dictionaries, e.g. names and brands
+	SegoHMM bool
+	// SegoConvert is t2s or s2t to make the sego analyzer convert Traditional
+	// Chinese to Simplified Chinese or the reverse, so that both scripts match
+	SegoConvert string
+	// SegoPosDrop drops the tokens of the sego analyzer tagged with these parts
+	// of speech, e.g. u for the particles, matched by prefix
+	SegoPosDrop []string
+	// SegoPosKeep keeps only the tokens of the sego analyzer tagged with these
+	// parts of speech, e.g. n and v, all if empty
+	SegoPosKeep []string
+	// Pinyin indexes the pinyin of the Chinese words of the titles and bodies,
+	// matched by Latin-only queries
+	Pinyin bool
+}
+
+// CustomAnalyzer chains a tokenizer, char filters applied before it and
+// token filters applied after it, all given by name
+type CustomAnalyzer struct {
+	Tokenizer    string
+	CharFilters  []string
+	TokenFilters []string
+}
+
+// Component is an analysis component of the given type, configured with
+// the options of the type
+type Component struct {
+	Type    string
+	Options map[string]interface{}
+}
+
+// SearchRequest describes a query against the index, Boosts raise the score
+// of the hits matching the query in the given fields by the boost of the field
+type SearchRequest struct {
+	Query     string
+	From      int
+	Size      int
+	Filters   []Filter
+	Sort      []string
+	Highlight *HighlightOptions
+	Facets    map[string]FacetRequest
+	Boosts    map[string]float64
+}
+
+// Filter restricts hits to the documents whose Field holds one of Values,
+// starts with Prefix or, for date fields, lies between Start and End.
+// Empty criteria are ignored, a zero Start or End leaves the range open.
+type Filter struct {
+	Field  string
+	Values []string
+	Prefix string
+	Start  time.Time
+	End    time.Time
+}
+
+// HighlightOptions controls how matched terms are marked in hits, a nil
+// *HighlightOptions disables highlighting. Up to Fragments fragments of about
+// FragmentSize characters are kept for each of Fields holding a match (all of
+// them when Fields is empty), with the matched terms between Before and After.
+// Titles are highlighted as a whole.
+type HighlightOptions struct {
+	Fields       []string
+	Fragments    int
+	FragmentSize int
+	Before       string
+	After        string
+}
+
+// FacetRequest asks for the breakdown of the hits over the values of Field,
+// either its Size most frequent terms or the given date ranges
+type FacetRequest struct {
+	Field      string
+	Size       int
+	DateRanges []DateRange
+}
+
+// DateRange is a named time interval, a zero Start or End leaves it open
+type DateRange struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// Facet is the breakdown of the hits for a FacetRequest
+type Facet struct {
+	Field      string
+	Total      int
+	Missing    int
+	Other      int
+	Terms      []FacetTerm
+	DateRanges []FacetDateRange
+}
+
+// FacetTerm counts the hits holding Term
+type FacetTerm struct {
+	Term  string
+	Count int
+}
+
+// FacetDateRange counts the hits within a DateRange
+type FacetDateRange struct {
+	DateRange
+	Count int
+}
+
+// SearchResponse is the outcome of a SearchRequest, Suggestion is a spelling
+// correction of the query when nothing matched
+type SearchResponse struct {
+	Hits       []Hit
+	Total      uint64
+	Took       time.Duration
+	Facets     map[string]Facet
+	Suggestion string
+	Err        error
+}
+
+// QueryError is the Err of a SearchResponse whose query is invalid, as
+// opposed to the failures of the index
+type QueryError struct {
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Hit is a matching record along with its score and highlighted fragments
+type Hit struct {
+	Record
+	Score     float64
+	Fragments map[string][]string
+}
+
+// Suggestions are the completions of a partially typed query, whole queries
+// ending with a known term and titles of matching documents
+type Suggestions struct {
+	Terms  []string
+	Titles []string
 }
 
 // Record ...
@@ -36,4 +213,6 @@ type Record interface {
 	Indexed() time.Time
 	MimeType() string
 	SetMimeType(string)
+	Meta(string) string
+	SetMeta(string, string)
 }
@@ -1,11 +1,9 @@
 package search
 
 import (
-	"bytes"
 	"io"
 	"log"
 	"os"
-	"path"
 	"runtime"
 	"strings"
 
@@ -17,6 +15,12 @@ import (
 
 var bm = bluemonday.StrictPolicy() //bluemonday.UGCPolicy()
 
+// htmlText returns the text of a HTML document. The bodies of every type of
+// document are stored as plain text, escaped when highlighted.
+func htmlText(doc []byte) []byte {
+	return []byte(html.UnescapeString(string(bm.SanitizeBytes(doc))))
+}
+
 // NewIndexerManager creates a new Pipeline instance
 func NewIndexerManager(config *Search, MaxFileSize int, indxr indexer.Handler) (*IndexerManager, error) {
 	ppl := &IndexerManager{
@@ -49,6 +53,8 @@ func NewIndexerManager(config *Search, MaxFileSize int, indxr indexer.Handler) (
 						}
 					}
 
+					// the content type declared by the server, its charset is lost on detection
+					declared := rc.MimeType()
 					var detectedMIME *mimetype.MIME = nil
 					if rc.MimeType() != "" {
 						detectedMIME = mimetype.Lookup(strings.Split(rc.MimeType(), ";")[0])
@@ -62,15 +68,11 @@ func NewIndexerManager(config *Search, MaxFileSize int, indxr indexer.Handler) (
 					}
 					if detectedMIME != nil {
 						rc.SetMimeType(detectedMIME.String())
-					}
-					isBinary := true
-					for mtype := detectedMIME; mtype != nil; mtype = mtype.Parent() {
-						if mtype.Is("text/plain") {
-							isBinary = false
-							break
+						if detectedMIME.Is("text/plain") && isMarkdown(rc) {
+							rc.SetMimeType("text/markdown")
 						}
 					}
-					if isBinary {
+					if LookupExtractor(rc.MimeType()) == nil {
 						rc.Ignore()
 						continue
 					}
@@ -84,6 +86,9 @@ func NewIndexerManager(config *Search, MaxFileSize int, indxr indexer.Handler) (
 						io.Copy(rc, in)
 						in.Close()
 					}
+					if isText(rc.MimeType()) {
+						rc.SetBody(toUTF8(rc.Body(), declared))
+					}
 
 					ppl.index(rc)
 				}
@@ -108,30 +113,90 @@ func (p *IndexerManager) Feed(record indexer.Record) {
 	p.queue <- record
 }
 
-func getHtmlTitle(r io.Reader, defval string) (result string, err error) {
+// htmlMetaNames are the <meta name="..."> tags kept as record metadata
+var htmlMetaNames = map[string]bool{
+	"description": true,
+	"keywords":    true,
+}
+
+// getHtmlMeta extracts the title and metadata of a HTML document: the description
+// and keywords meta tags, the lang attribute, the canonical link, the og:* meta
+// tags and the text of the h1 to h3 headings (one per line)
+func getHtmlMeta(r io.Reader, defval string) (title string, meta map[string]string, err error) {
 	z := html.NewTokenizer(r)
-	result = ""
-	intag := false
+	meta = make(map[string]string)
+	headings := make([]string, 0)
+	intitle := false
+	inheading := ""
+	heading := ""
 	for {
 		switch z.Next() {
 		case html.ErrorToken:
 			err = z.Err()
-			return defval, err
-		case html.StartTagToken:
-			tn, _ := z.TagName()
-			if strings.ToLower(string(tn)) == "title" {
-				intag = true
+			if err == io.EOF {
+				err = nil
+			}
+			if len(headings) > 0 {
+				meta["headings"] = strings.Join(headings, "\n")
+			}
+			title = strings.TrimSpace(title)
+			if title == "" {
+				title = meta["og:title"]
+			}
+			if title == "" {
+				title = defval
+			}
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tn, hasAttr := z.TagName()
+			tag := strings.ToLower(string(tn))
+			attrs := make(map[string]string)
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				attrs[strings.ToLower(string(key))] = string(val)
+			}
+			switch tag {
+			case "title":
+				intitle = title == ""
+			case "h1", "h2", "h3":
+				inheading = tag
+				heading = ""
+			case "html":
+				if attrs["lang"] != "" {
+					meta["lang"] = strings.ToLower(attrs["lang"])
+				}
+			case "link":
+				if strings.ToLower(attrs["rel"]) == "canonical" && attrs["href"] != "" {
+					meta["canonical"] = attrs["href"]
+				}
+			case "meta":
+				name := strings.ToLower(attrs["name"])
+				property := strings.ToLower(attrs["property"])
+				if htmlMetaNames[name] {
+					meta[name] = strings.TrimSpace(attrs["content"])
+				} else if strings.HasPrefix(property, "og:") {
+					meta[property] = strings.TrimSpace(attrs["content"])
+				}
 			}
 		case html.EndTagToken:
-			//tn, _ := z.TagName()
-			if /*strings.ToLower(string(tn)) == "title" &&*/ intag {
-				return result, nil
+			tn, _ := z.TagName()
+			tag := strings.ToLower(string(tn))
+			if tag == "title" {
+				intitle = false
+			} else if tag == inheading {
+				if heading = strings.Join(strings.Fields(heading), " "); heading != "" {
+					headings = append(headings, heading)
+				}
+				inheading = ""
 			}
 		case html.TextToken:
-			if intag {
-				result = result + string(z.Text())
+			if intitle {
+				title = title + string(z.Text())
+			}
+			if inheading != "" {
+				heading = heading + string(z.Text())
 			}
-
 		}
 	}
 }
@@ -147,22 +212,55 @@ func (p *IndexerManager) index(record indexer.Record) {
 		return
 	}
 
-	var detectedMIME *mimetype.MIME = mimetype.Lookup(strings.Split(record.MimeType(), ";")[0])
+	extractor := LookupExtractor(record.MimeType())
+	if extractor == nil {
+		record.Ignore()
+		return
+	}
 
-	if detectedMIME != nil && detectedMIME.Is("text/html") {
-		body := bytes.NewReader(record.Body())
-		title, _ := getHtmlTitle(body, path.Base(record.Path()))
-		record.SetTitle(title)
-		stripped := bm.SanitizeBytes(record.Body())
-		log.Printf("Size %v/%v: %v", len(stripped), len(record.Body()), record.Path())
-		record.SetBody(stripped)
+	size := len(record.Body())
+	var ok bool
+	var err error
+	var parts []indexer.Record
+	if partsExtractor, isParts := extractor.(PartsExtractor); isParts {
+		ok, err = partsExtractor.ExtractParts(record, func(name string) indexer.Record {
+			part := p.indexer.Record(record.Path() + "#" + name)
+			part.SetFullPath(record.FullPath())
+			part.SetModified(record.Modified())
+			part.SetMimeType(record.MimeType())
+			parts = append(parts, part)
+			return part
+		})
 	} else {
-		log.Printf("Size %v: %v", len(record.Body()), record.Path())
-		record.SetTitle(path.Base(record.Path()))
+		ok, err = extractor.Extract(record)
+	}
+	if err != nil {
+		log.Printf("Ignored: %v %v", record.Path(), err)
+		record.Ignore()
+		return
+	}
+	if !ok {
+		log.Printf("Skipped: %v", record.Path())
+		record.Ignore()
+		p.indexer.Delete(record.Path())
+		return
+	}
+	log.Printf("Size %v/%v: %v", len(record.Body()), size, record.Path())
+
+	detectLanguage(record)
+	for _, part := range parts {
+		detectLanguage(part)
 	}
 
 	if !record.Ignored() {
+		if parts != nil {
+			// drop the parts which are gone since the last time
+			p.indexer.Delete(record.Path())
+		}
 		p.indexer.Index(record)
+		for _, part := range parts {
+			p.indexer.Index(part)
+		}
 	}
 }
 
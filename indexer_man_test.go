@@ -0,0 +1,53 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetHtmlMeta(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		title string
+		meta  map[string]string
+	}{
+		{"empty", "", "default", map[string]string{}},
+		{"title", "<html><head><title> A  page </title></head></html>", "A  page", map[string]string{}},
+		{"first title", "<title>First</title><svg><title>Second</title></svg>", "First", map[string]string{}},
+		{"meta names", `<html lang="EN-us"><head>
+			<meta name="Description" content=" About caddy ">
+			<meta name="keywords" content="caddy, search">
+			<meta name="viewport" content="width=device-width">
+			<link rel="Canonical" href="https://example.com/a">
+			<link rel="stylesheet" href="/a.css">
+			</head></html>`, "default", map[string]string{
+			"description": "About caddy",
+			"keywords":    "caddy, search",
+			"lang":        "en-us",
+			"canonical":   "https://example.com/a",
+		}},
+		{"open graph", `<meta property="og:title" content="Graph title"><meta property="OG:Image" content="/a.png"/>`,
+			"Graph title", map[string]string{"og:title": "Graph title", "og:image": "/a.png"}},
+		{"title before open graph", `<title>Page</title><meta property="og:title" content="Graph title">`,
+			"Page", map[string]string{"og:title": "Graph title"}},
+		{"headings", `<h1>Main <em>title</em></h1><p>text</p><h2>
+			Section</h2><h3></h3><h4>Ignored</h4><h3>Sub</h3>`,
+			"default", map[string]string{"headings": "Main title\nSection\nSub"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			title, meta, err := getHtmlMeta(strings.NewReader(test.doc), "default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if title != test.title {
+				t.Errorf("got title %q, want %q", title, test.title)
+			}
+			if !reflect.DeepEqual(meta, test.meta) {
+				t.Errorf("got meta %q, want %q", meta, test.meta)
+			}
+		})
+	}
+}
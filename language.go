@@ -0,0 +1,75 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+// minHanRatio is the share of Han characters among the letters of a Chinese text
+const minHanRatio = 0.3
+
+// minStopwords is the number of stopwords a text needs to be recognized
+const minStopwords = 3
+
+// stopwords are the most frequent words of the languages recognized from the text
+var stopwords = map[string]map[string]bool{
+	"en": wordSet("the of and to in is that for it with as was on are be by this from or have not but at which"),
+	"de": wordSet("der die und in den von zu das mit sich des auf für ist im dem nicht ein eine als auch es an werden aus er hat dass"),
+	"fr": wordSet("le la les de des et en un une du est que qui dans pour pas sur au avec il elle ce sont par plus ne"),
+}
+
+// wordSet returns the set of the space separated words
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// detectLanguage sets the lang metadata of a record lacking it to the language
+// of its body, when recognized: Chinese, English, German or French
+func detectLanguage(record indexer.Record) {
+	if record.Meta("lang") != "" {
+		return
+	}
+	if lang := textLanguage(string(record.Body())); lang != "" {
+		record.SetMeta("lang", lang)
+	}
+}
+
+// textLanguage guesses the language of text, from its share of Han characters
+// or else from the stopwords it holds
+func textLanguage(text string) string {
+	letters, han := 0, 0
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for _, r := range word {
+			letters++
+			if unicode.Is(unicode.Han, r) {
+				han++
+			}
+		}
+		word = strings.ToLower(word)
+		for lang, words := range stopwords {
+			if words[word] {
+				counts[lang]++
+			}
+		}
+	}
+	if letters > 0 && float64(han)/float64(letters) >= minHanRatio {
+		return "zh"
+	}
+
+	best, max := "", minStopwords-1
+	for lang, count := range counts {
+		if count > max || count == max && best != "" && lang < best {
+			best, max = lang, count
+		}
+	}
+	return best
+}
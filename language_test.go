@@ -0,0 +1,47 @@
+package search
+
+import "testing"
+
+func TestTextLanguage(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"", ""},
+		{"搜索引擎帮助用户找到信息", "zh"},
+		{"Caddy 是一个 web 服务器", "zh"},
+		{"The quick fox is in the garden with the dog", "en"},
+		{"Der Hund und die Katze sind in dem Garten", "de"},
+		{"Le chat et le chien sont dans la maison", "fr"},
+		// too few stopwords
+		{"The caddy server", ""},
+		{"caddy nginx apache", ""},
+		// Han characters among many Latin letters
+		{"the caddy web server is in the box with 中", "en"},
+	}
+	for _, test := range tests {
+		if got := textLanguage(test.text); got != test.want {
+			t.Errorf("textLanguage(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	rec := newTestRecord("/a.txt", "The quick fox is in the garden with the dog")
+	detectLanguage(rec)
+	if got := rec.Meta("lang"); got != "en" {
+		t.Errorf("got %q, want en", got)
+	}
+
+	rec = newTestRecord("/a.html", "The quick fox is in the garden with the dog")
+	rec.SetMeta("lang", "fr-ca")
+	detectLanguage(rec)
+	if got := rec.Meta("lang"); got != "fr-ca" {
+		t.Errorf("got %q, want the declared language", got)
+	}
+
+	rec = newTestRecord("/a.txt", "caddy nginx apache")
+	detectLanguage(rec)
+	if got := rec.Meta("lang"); got != "" {
+		t.Errorf("got %q, want none", got)
+	}
+}
@@ -0,0 +1,164 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+	"github.com/yuin/goldmark"
+	"gopkg.in/yaml.v3"
+)
+
+// markdownExtensions are the extensions of the files indexed as Markdown
+var markdownExtensions = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".mdown":    true,
+}
+
+// isMarkdown reports if the record is a Markdown source file
+func isMarkdown(record indexer.Record) bool {
+	name := record.FullPath()
+	if name == "" {
+		name = strings.SplitN(record.Path(), "?", 2)[0]
+	}
+	return markdownExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// frontMatterDates are the layouts accepted for the front matter date
+var frontMatterDates = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// splitFrontMatter separates the YAML (---) or TOML (+++) front matter of a
+// Markdown document from its content, fm is nil if there is no front matter
+func splitFrontMatter(doc []byte) (fm map[string]interface{}, content []byte, err error) {
+	doc = bytes.TrimPrefix(doc, []byte("\xef\xbb\xbf"))
+	var fence []byte
+	var unmarshal func([]byte, interface{}) error
+	switch {
+	case bytes.HasPrefix(doc, []byte("---")):
+		fence, unmarshal = []byte("---"), yaml.Unmarshal
+	case bytes.HasPrefix(doc, []byte("+++")):
+		fence, unmarshal = []byte("+++"), toml.Unmarshal
+	default:
+		return nil, doc, nil
+	}
+
+	// the opening fence must be alone on its line
+	firstLine := doc
+	if n := bytes.IndexByte(doc, '\n'); n >= 0 {
+		firstLine = doc[:n]
+	}
+	if len(bytes.TrimSpace(firstLine)) != len(fence) {
+		return nil, doc, nil
+	}
+
+	rest := doc[len(firstLine):]
+	end := bytes.Index(rest, append([]byte("\n"), fence...))
+	if end < 0 {
+		return nil, doc, fmt.Errorf("unterminated front matter")
+	}
+	content = rest[end+1+len(fence):]
+	if n := bytes.IndexByte(content, '\n'); n >= 0 {
+		content = content[n+1:]
+	} else {
+		content = nil
+	}
+
+	fm = make(map[string]interface{})
+	if err := unmarshal(rest[:end], &fm); err != nil {
+		return nil, doc, err
+	}
+	return fm, content, nil
+}
+
+// frontMatterString returns the front matter value of key as a string
+func frontMatterString(fm map[string]interface{}, key string) string {
+	switch v := fm[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// frontMatterStrings returns the front matter value of key as a list of strings,
+// either a list or a comma separated string
+func frontMatterStrings(fm map[string]interface{}, key string) []string {
+	ret := make([]string, 0)
+	switch v := fm[key].(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+				ret = append(ret, s)
+			}
+		}
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if s := strings.TrimSpace(item); s != "" {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return ret
+}
+
+// frontMatterDate returns the front matter date, the zero time if it has none
+func frontMatterDate(fm map[string]interface{}) time.Time {
+	switch v := fm["date"].(type) {
+	case time.Time:
+		return v
+	case string:
+		for _, layout := range frontMatterDates {
+			if t, err := time.ParseInLocation(layout, strings.TrimSpace(v), time.Local); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// extractMarkdown fills the record from a Markdown document: the front matter
+// title, tags, date and description, and the rendered text as body. Drafts are
+// not indexed.
+func extractMarkdown(record indexer.Record) (bool, error) {
+	fm, content, err := splitFrontMatter(record.Body())
+	if err != nil {
+		// index the document as it is rather than losing it
+		fm, content = nil, record.Body()
+	}
+
+	if frontMatterString(fm, "draft") == "true" {
+		return false, nil
+	}
+
+	title := frontMatterString(fm, "title")
+	if title == "" {
+		title = path.Base(record.Path())
+	}
+	record.SetTitle(title)
+	record.SetMeta("description", frontMatterString(fm, "description"))
+	record.SetMeta("keywords", strings.Join(frontMatterStrings(fm, "tags"), ", "))
+	if date := frontMatterDate(fm); !date.IsZero() {
+		record.SetModified(date)
+	}
+
+	var rendered bytes.Buffer
+	if err := goldmark.Convert(content, &rendered); err != nil {
+		record.SetBody(content)
+		return true, nil
+	}
+	record.SetBody(htmlText(rendered.Bytes()))
+	return true, nil
+}
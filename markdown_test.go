@@ -0,0 +1,140 @@
+package search
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		fm      map[string]interface{}
+		content string
+		err     bool
+	}{
+		{"none", "# Title\ntext", nil, "# Title\ntext", false},
+		{"yaml", "---\ntitle: A title\ndraft: true\n---\n# Title\n",
+			map[string]interface{}{"title": "A title", "draft": true}, "# Title\n", false},
+		{"toml", "+++\ntitle = \"A title\"\ntags = [\"a\", \"b\"]\n+++\ntext",
+			map[string]interface{}{"title": "A title", "tags": []interface{}{"a", "b"}}, "text", false},
+		{"byte order mark", "\xef\xbb\xbf---\ntitle: A title\n---\ntext",
+			map[string]interface{}{"title": "A title"}, "text", false},
+		{"crlf", "---\r\ntitle: A title\r\n---\r\ntext",
+			map[string]interface{}{"title": "A title"}, "text", false},
+		{"empty content", "---\ntitle: A title\n---", map[string]interface{}{"title": "A title"}, "", false},
+		{"thematic break", "----\ntext\n---\n", nil, "----\ntext\n---\n", false},
+		{"unterminated", "---\ntitle: A title\ntext", nil, "---\ntitle: A title\ntext", true},
+		{"invalid yaml", "---\ntitle: [\n---\ntext", nil, "---\ntitle: [\n---\ntext", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fm, content, err := splitFrontMatter([]byte(test.doc))
+			if (err != nil) != test.err {
+				t.Fatalf("error %v", err)
+			}
+			if len(fm) != len(test.fm) || (fm == nil) != (test.fm == nil) {
+				t.Fatalf("got front matter %v, want %v", fm, test.fm)
+			}
+			for key, want := range test.fm {
+				if got := frontMatterString(fm, key); got != frontMatterString(test.fm, key) {
+					t.Errorf("got %v %q, want %q", key, got, want)
+				}
+			}
+			if test.err {
+				return
+			}
+			if string(content) != test.content {
+				t.Errorf("got content %q, want %q", content, test.content)
+			}
+		})
+	}
+}
+
+func TestFrontMatterValues(t *testing.T) {
+	fm := map[string]interface{}{
+		"title":  " A title ",
+		"weight": 3,
+		"tags":   []interface{}{"a", " b ", ""},
+		"cats":   "a, b,,c",
+	}
+	if got := frontMatterString(fm, "title"); got != "A title" {
+		t.Errorf("got title %q", got)
+	}
+	if got := frontMatterString(fm, "weight"); got != "3" {
+		t.Errorf("got weight %q", got)
+	}
+	if got := frontMatterString(fm, "missing"); got != "" {
+		t.Errorf("got missing %q", got)
+	}
+	if got := strings.Join(frontMatterStrings(fm, "tags"), "|"); got != "a|b" {
+		t.Errorf("got tags %q", got)
+	}
+	if got := strings.Join(frontMatterStrings(fm, "cats"), "|"); got != "a|b|c" {
+		t.Errorf("got cats %q", got)
+	}
+
+	day := time.Date(2021, 3, 4, 0, 0, 0, 0, time.Local)
+	for date, want := range map[interface{}]time.Time{
+		"2021-03-04":           day,
+		"2021-03-04 05:06":     day.Add(5*time.Hour + 6*time.Minute),
+		"2021-03-04T05:06:07Z": time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		day:                    day,
+		"yesterday":            {},
+		20210304:               {},
+	} {
+		if got := frontMatterDate(map[string]interface{}{"date": date}); !got.Equal(want) {
+			t.Errorf("got date %v for %v, want %v", got, date, want)
+		}
+	}
+}
+
+func TestExtractMarkdown(t *testing.T) {
+	modified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		doc      string
+		ok       bool
+		title    string
+		keywords string
+		modified time.Time
+		body     string
+	}{
+		{"plain", "# Title\n\nSome *text*.\n", true, "a.md", "", modified, "Title\nSome text.\n"},
+		{"front matter", "---\ntitle: A title\ntags: [a, b]\ndate: 2021-03-04\n---\ntext\n",
+			true, "A title", "a, b", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), "text\n"},
+		{"draft", "---\ntitle: A title\ndraft: true\n---\ntext\n", false, "", "", modified, ""},
+		{"toml draft", "+++\ndraft = true\n+++\ntext\n", false, "", "", modified, ""},
+		{"not a draft", "---\ndraft: false\n---\ntext\n", true, "a.md", "", modified, "text\n"},
+		{"invalid front matter", "---\ntitle: [\n---\ntext\n", true, "a.md", "", modified, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := newTestRecord("/blog/a.md", test.doc)
+			rec.SetModified(modified)
+			ok, err := extractMarkdown(rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != test.ok {
+				t.Fatalf("got %v, want %v", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+			if rec.Title() != test.title {
+				t.Errorf("got title %q, want %q", rec.Title(), test.title)
+			}
+			if rec.Meta("keywords") != test.keywords {
+				t.Errorf("got keywords %q, want %q", rec.Meta("keywords"), test.keywords)
+			}
+			if !rec.Modified().Equal(test.modified) {
+				t.Errorf("got modified %v, want %v", rec.Modified(), test.modified)
+			}
+			if test.body != "" && string(rec.Body()) != test.body {
+				t.Errorf("got body %q, want %q", rec.Body(), test.body)
+			}
+		})
+	}
+}
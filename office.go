@@ -0,0 +1,231 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+// maxOfficePartSize bounds the uncompressed size read from an archive member
+const maxOfficePartSize = 32 << 20
+
+// officeFormat describes where an Office Open XML or OpenDocument archive keeps
+// its text and its properties
+type officeFormat struct {
+	// parts returns the names of the members holding the text, in reading order
+	parts func(names []string) []string
+	// text is the local name of the elements holding text, any element if empty
+	text string
+	// properties is the member holding the title and the author
+	properties string
+}
+
+// officeFormats maps the mime types of Office documents to their format
+var officeFormats = map[string]officeFormat{
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": {
+		parts:      officeMembers("word/document.xml"),
+		text:       "t",
+		properties: "docProps/core.xml",
+	},
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": {
+		parts:      numberedMembers("xl/sharedStrings.xml", "xl/worksheets/sheet"),
+		text:       "t",
+		properties: "docProps/core.xml",
+	},
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": {
+		parts:      numberedMembers("", "ppt/slides/slide"),
+		text:       "t",
+		properties: "docProps/core.xml",
+	},
+	"application/vnd.oasis.opendocument.text": {
+		parts:      officeMembers("content.xml"),
+		properties: "meta.xml",
+	},
+	"application/vnd.oasis.opendocument.spreadsheet": {
+		parts:      officeMembers("content.xml"),
+		properties: "meta.xml",
+	},
+	"application/vnd.oasis.opendocument.presentation": {
+		parts:      officeMembers("content.xml"),
+		properties: "meta.xml",
+	},
+}
+
+func init() {
+	for mimeType, format := range officeFormats {
+		RegisterExtractor(mimeType, format)
+	}
+}
+
+// officeMembers returns the given members
+func officeMembers(members ...string) func([]string) []string {
+	return func([]string) []string {
+		return members
+	}
+}
+
+// numberedMembers returns first followed by the members named prefix<n>.xml, ordered by n
+func numberedMembers(first, prefix string) func([]string) []string {
+	return func(names []string) []string {
+		numbers := make(map[string]int)
+		members := make([]string, 0)
+		for _, name := range names {
+			if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".xml") {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".xml"))
+			if err != nil {
+				continue
+			}
+			numbers[name] = n
+			members = append(members, name)
+		}
+		sort.Slice(members, func(i, j int) bool {
+			return numbers[members[i]] < numbers[members[j]]
+		})
+		if first != "" {
+			members = append([]string{first}, members...)
+		}
+		return members
+	}
+}
+
+// Extract indexes the text of an Office document, titled by its title property
+// or else by its file name
+func (f officeFormat) Extract(record indexer.Record) (bool, error) {
+	body := record.Body()
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return false, err
+	}
+	files := make(map[string]*zip.File)
+	names := make([]string, 0, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+		names = append(names, file.Name)
+	}
+
+	var text strings.Builder
+	for _, name := range f.parts(names) {
+		file, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := readZipXML(file, func(r io.Reader) error {
+			return xmlText(r, f.text, &text)
+		}); err != nil {
+			return false, fmt.Errorf("%v: %v", name, err)
+		}
+	}
+
+	title := ""
+	if file, ok := files[f.properties]; ok {
+		var props map[string]string
+		err := readZipXML(file, func(r io.Reader) (err error) {
+			props, err = xmlProperties(r)
+			return err
+		})
+		if err == nil {
+			title = props["title"]
+			if author := props["creator"]; author != "" {
+				record.SetMeta("author", author)
+			} else if author := props["initial-creator"]; author != "" {
+				record.SetMeta("author", author)
+			}
+		}
+	}
+	if title == "" {
+		title = path.Base(record.Path())
+	}
+	record.SetTitle(title)
+	record.SetBody([]byte(text.String()))
+	return true, nil
+}
+
+// readZipXML calls read with the content of an archive member
+func readZipXML(file *zip.File, read func(io.Reader) error) error {
+	if file.UncompressedSize64 > maxOfficePartSize {
+		return fmt.Errorf("member too large")
+	}
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return read(io.LimitReader(r, maxOfficePartSize))
+}
+
+// xmlText writes the character data of the elements named text (or of every
+// element if empty) to w, one paragraph, heading, cell or shared string per line
+func xmlText(r io.Reader, text string, w *strings.Builder) error {
+	d := xml.NewDecoder(r)
+	depth := 0
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if text == "" || t.Name.Local == text {
+				depth++
+			}
+			switch t.Name.Local {
+			case "tab", "s":
+				w.WriteString(" ")
+			case "br", "line-break":
+				w.WriteString("\n")
+			}
+		case xml.EndElement:
+			if text == "" || t.Name.Local == text {
+				depth--
+			}
+			switch t.Name.Local {
+			case "p", "h", "si", "c", "table-cell":
+				w.WriteString("\n")
+			}
+		case xml.CharData:
+			if depth > 0 {
+				w.Write(t)
+			}
+		}
+	}
+}
+
+// xmlProperties returns the text of the top level properties of a Dublin Core
+// properties document, keyed by their local name
+func xmlProperties(r io.Reader) (map[string]string, error) {
+	props := make(map[string]string)
+	d := xml.NewDecoder(r)
+	name := ""
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return props, nil
+		}
+		if err != nil {
+			return props, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			name = t.Name.Local
+		case xml.EndElement:
+			name = ""
+		case xml.CharData:
+			if value := strings.TrimSpace(string(t)); name != "" && value != "" && props[name] == "" {
+				props[name] = value
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testZip returns an archive holding the given members, a name followed by
+// its content
+func testZip(members ...string) []byte {
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for n := 0; n+1 < len(members); n += 2 {
+		f, err := w.Create(members[n])
+		if err != nil {
+			panic(err)
+		}
+		f.Write([]byte(members[n+1]))
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+	return archive.Bytes()
+}
+
+const testCoreProperties = `<?xml version="1.0"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"
+	xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<dc:title>A title</dc:title><dc:creator>An author</dc:creator>
+</cp:coreProperties>`
+
+func TestExtractOffice(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		body     []byte
+		title    string
+		author   string
+		text     string
+		err      bool
+	}{
+		{"docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", testZip(
+			"word/document.xml", `<w:document xmlns:w="w"><w:body>
+				<w:p><w:r><w:t>First</w:t><w:tab/><w:t>paragraph</w:t></w:r></w:p>
+				<w:p><w:r><w:t>Second</w:t><w:br/><w:t>line</w:t></w:r><w:r><w:instrText>ignored</w:instrText></w:r></w:p>
+			</w:body></w:document>`,
+			"docProps/core.xml", testCoreProperties,
+		), "A title", "An author", "First paragraph\nSecond\nline\n", false},
+		{"xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", testZip(
+			"xl/worksheets/sheet10.xml", `<worksheet><sheetData><row><c t="inlineStr"><is><t>tenth</t></is></c></row></sheetData></worksheet>`,
+			"xl/worksheets/sheet2.xml", `<worksheet><sheetData><row><c t="inlineStr"><is><t>second</t></is></c></row></sheetData></worksheet>`,
+			"xl/sharedStrings.xml", `<sst><si><t>shared</t></si><si><t>strings</t></si></sst>`,
+		), "a.doc", "", "shared\nstrings\nsecond\ntenth\n", false},
+		{"pptx", "application/vnd.openxmlformats-officedocument.presentationml.presentation", testZip(
+			"ppt/slides/slide2.xml", `<p:sld xmlns:p="p" xmlns:a="a"><a:p><a:t>Second slide</a:t></a:p></p:sld>`,
+			"ppt/slides/slide1.xml", `<p:sld xmlns:p="p" xmlns:a="a"><a:p><a:t>First slide</a:t></a:p></p:sld>`,
+			"ppt/slides/_rels/slide1.xml.rels", `<Relationships/>`,
+		), "a.doc", "", "First slide\nSecond slide\n", false},
+		{"odt", "application/vnd.oasis.opendocument.text", testZip(
+			"content.xml", `<office:document-content xmlns:office="o" xmlns:text="t"><office:body><office:text>
+				<text:h>Heading</text:h><text:p>Some<text:s/>text<text:line-break/>more</text:p>
+			</office:text></office:body></office:document-content>`,
+			"meta.xml", `<office:document-meta xmlns:office="o" xmlns:meta="m" xmlns:dc="d"><office:meta>
+				<meta:initial-creator>First author</meta:initial-creator><dc:title>An ODF title</dc:title>
+			</office:meta></office:document-meta>`,
+		), "An ODF title", "First author", "Heading\nSome text\nmore\n", false},
+		{"markup", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", testZip(
+			"word/document.xml", `<w:document xmlns:w="w"><w:body>
+				<w:p><w:r><w:t>&lt;script&gt;alert(1)&lt;/script&gt; a &lt; b</w:t></w:r></w:p>
+			</w:body></w:document>`,
+			"docProps/core.xml", strings.Replace(testCoreProperties, "A title", "&lt;b&gt;A title&lt;/b&gt;", 1),
+		), "<b>A title</b>", "An author", "<script>alert(1)</script> a < b\n", false},
+		{"not an archive", "application/vnd.oasis.opendocument.text", []byte("some text"), "", "", "", true},
+		{"invalid member", "application/vnd.oasis.opendocument.text", testZip("content.xml", "<a><b></a>"), "", "", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := newTestRecord("/docs/a.doc", "")
+			rec.SetBody(test.body)
+			extractor := LookupExtractor(test.mimeType)
+			if extractor == nil {
+				t.Fatalf("no extractor of %v", test.mimeType)
+			}
+			ok, err := extractor.Extract(rec)
+			if (err != nil) != test.err || ok == test.err {
+				t.Fatalf("got %v, %v", ok, err)
+			}
+			if test.err {
+				return
+			}
+			if rec.Title() != test.title || rec.Meta("author") != test.author {
+				t.Errorf("got title %q and author %q, want %q and %q", rec.Title(), rec.Meta("author"), test.title, test.author)
+			}
+			if body := normalizeSpace(string(rec.Body())); body != test.text {
+				t.Errorf("got body %q, want %q", body, test.text)
+			}
+		})
+	}
+}
+
+// normalizeSpace trims the lines of s and drops its blank lines, left by the
+// indentation of the test documents
+func normalizeSpace(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line+"\n")
+		}
+	}
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,54 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+	"github.com/ledongthuc/pdf"
+)
+
+// extractPdf indexes the text of the pages of a PDF document, titled by the
+// title of its document information or else by its file name, with its author
+func extractPdf(record indexer.Record) (ok bool, err error) {
+	defer func() {
+		// the PDF reader panics on some malformed documents
+		if r := recover(); r != nil {
+			ok, err = false, fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	body := record.Body()
+	r, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return false, err
+	}
+
+	var text strings.Builder
+	for i := 1; i <= r.NumPage(); i++ {
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		content, err := page.GetPlainText(nil)
+		if err != nil {
+			return false, err
+		}
+		text.WriteString(content)
+		text.WriteString("\n")
+	}
+
+	info := r.Trailer().Key("Info")
+	if author := strings.TrimSpace(info.Key("Author").Text()); author != "" {
+		record.SetMeta("author", author)
+	}
+	title := strings.TrimSpace(info.Key("Title").Text())
+	if title == "" {
+		title = path.Base(record.Path())
+	}
+	record.SetTitle(title)
+	record.SetBody([]byte(text.String()))
+	return true, nil
+}
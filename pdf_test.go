@@ -0,0 +1,82 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testPdf returns a PDF document holding a page per text, with the given
+// document information dictionary, e.g. "/Title (A title)"
+func testPdf(info string, texts ...string) []byte {
+	var objects []string
+	kids := make([]string, len(texts))
+	for n, text := range texts {
+		content := fmt.Sprintf("BT /F1 12 Tf 72 712 Td (%s) Tj ET", text)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources << /Font << /F1 3 0 R >> >> >>", 6+2*n),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		kids[n] = fmt.Sprintf("%d 0 R", 5+2*n)
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(texts)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< %s >>", info),
+	}, objects...)
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for n, object := range objects {
+		offsets[n] = doc.Len()
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", n+1, object)
+	}
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return doc.Bytes()
+}
+
+func TestExtractPdf(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   []byte
+		ok     bool
+		title  string
+		author string
+		text   []string
+	}{
+		{"pages", testPdf("/Title (A title) /Author (An author)", "First page", "Second page"),
+			true, "A title", "An author", []string{"First page", "Second page"}},
+		{"no title", testPdf("/Producer (test)", "Some text"), true, "a.pdf", "", []string{"Some text"}},
+		{"not a PDF", []byte("some text"), false, "", "", nil},
+		{"truncated", testPdf("/Title (A title)", "Some text")[:200], false, "", "", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := newTestRecord("/docs/a.pdf", "")
+			rec.SetBody(test.body)
+			ok, err := extractPdf(rec)
+			if ok != test.ok || (err == nil) != test.ok {
+				t.Fatalf("got %v, %v, want %v", ok, err, test.ok)
+			}
+			if !ok {
+				return
+			}
+			if rec.Title() != test.title || rec.Meta("author") != test.author {
+				t.Errorf("got title %q and author %q, want %q and %q", rec.Title(), rec.Meta("author"), test.title, test.author)
+			}
+			body := string(rec.Body())
+			for _, text := range test.text {
+				if !strings.Contains(body, text) {
+					t.Errorf("got body %q, want %q in it", body, text)
+				}
+			}
+		})
+	}
+}
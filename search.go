@@ -3,10 +3,16 @@ package search
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
 	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	//TODO remove this
 	"github.com/caddyserver/caddy/caddyhttp/httpserver"
@@ -23,11 +29,18 @@ func (s *Search) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhtt
 		}
 		return s.SearchHTML(w, r)
 	}
+	if r.URL.Path == s.Endpoint+"/suggest" {
+		return s.SuggestJSON(w, r)
+	}
 
 	record := s.Indexer.Record(GetUrlPath(r.URL))
 
 	err := next.ServeHTTP(&searchResponseWriter{w, record}, r)
 
+	// keep the declared content type for its charset, unless it says nothing
+	if contentType := w.Header().Get("Content-Type"); contentType != "" && baseMimeType(contentType) != "application/octet-stream" {
+		record.SetMimeType(contentType)
+	}
 	modif := w.Header().Get("Last-Modified")
 	if len(modif) > 0 {
 		modTime, err := time.Parse(`Mon, 2 Jan 2006 15:04:05 MST`, modif)
@@ -47,80 +60,326 @@ func (s *Search) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhtt
 
 // Result is the structure for the search result
 type Result struct {
-	Path     string
-	Title    string
-	Body     template.HTML
-	Json     string
-	Modified time.Time
-	Indexed  time.Time
-	From     int
-	Size     int
+	Path      string
+	Title     string
+	TitleHTML template.HTML
+	Body      template.HTML
+	Json      string
+	Score     float64
+	Modified  time.Time
+	Indexed   time.Time
+	From      int
+	Size      int
 }
 
-// SearchJSON renders the search results in JSON format
-func (s *Search) SearchJSON(w http.ResponseWriter, r *http.Request) error {
+// highlightOptions returns the highlighting configured for the search results
+func (s *Search) highlightOptions() *indexer.HighlightOptions {
+	fields := []string{"Body", "Description"}
+	if s.HighlightTitle {
+		fields = append(fields, "Title")
+	}
+	return &indexer.HighlightOptions{
+		Fields:       fields,
+		Fragments:    s.Fragments,
+		FragmentSize: s.FragmentSize,
+		Before:       s.HighlightBefore,
+		After:        s.HighlightAfter,
+	}
+}
+
+// snippet returns the highlighted fragments of the body of hit or, when the
+// body holds no match, its description or the beginning of the body
+func (s *Search) snippet(hit indexer.Hit) string {
+	if fragments := hit.Fragments["Body"]; len(fragments) > 0 {
+		return strings.Join(fragments, " … ")
+	}
+	if fragments := hit.Fragments["Description"]; len(fragments) > 0 {
+		return fragments[0]
+	}
+	if description := hit.Meta("description"); description != "" {
+		return html.EscapeString(description)
+	}
+	return extract(string(hit.Body()), s.SnippetSize)
+}
+
+// titleHTML returns the highlighted title of hit
+func (s *Search) titleHTML(hit indexer.Hit) template.HTML {
+	if fragments := hit.Fragments["Title"]; len(fragments) > 0 {
+		return template.HTML(fragments[0])
+	}
+	return template.HTML(html.EscapeString(hit.Title()))
+}
+
+// extract returns the first size characters of text with collapsed spaces, HTML escaped
+func extract(text string, size int) string {
+	if size <= 0 {
+		return ""
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) > size {
+		runes := []rune(text)
+		text = string(runes[:size]) + "…"
+	}
+	return html.EscapeString(text)
+}
+
+// Response is the structure for a page of search results
+type Response struct {
+	Query      string
+	From       int
+	Size       int
+	Total      uint64
+	Took       string
+	Error      string
+	Suggestion string
+	After      string
+	Before     string
+	Path       string
+	Mime       []string
+	Section    []string
+	Sort       string
+	Facets     map[string]indexer.Facet
+	Results    []Result
+}
+
+// defaultSize is the number of results of a page, unless given by the s parameter
+const defaultSize = 100
+
+// Link returns the query string searching again with the given parameters
+// replaced, as pairs of name and value, an empty value dropping the parameter.
+// The other parameters of the search are kept, so that the filters of the
+// facets combine, and the results start over from the first one.
+func (resp Response) Link(pairs ...string) template.URL {
+	params := url.Values{}
+	params.Set("q", resp.Query)
+	if resp.Size != defaultSize {
+		params.Set("s", strconv.Itoa(resp.Size))
+	}
+	for name, value := range map[string]string{"after": resp.After, "before": resp.Before, "path": resp.Path, "sort": resp.Sort} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+	for _, mime := range resp.Mime {
+		params.Add("mime", mime)
+	}
+	for _, section := range resp.Section {
+		params.Add("section", section)
+	}
+	for n := 0; n+1 < len(pairs); n += 2 {
+		if pairs[n+1] == "" {
+			params.Del(pairs[n])
+		} else {
+			params.Set(pairs[n], pairs[n+1])
+		}
+	}
+	return template.URL("?" + params.Encode())
+}
+
+// DateLink is the Link to the documents modified between start and end, a
+// zero time leaving the range open. The times are kept whole, so that the
+// results match the counts of the date range facets.
+func (resp Response) DateLink(start, end time.Time) template.URL {
+	var after, before string
+	if !start.IsZero() {
+		after = start.Format(time.RFC3339)
+	}
+	if !end.IsZero() {
+		before = end.Format(time.RFC3339)
+	}
+	return resp.Link("after", after, "before", before)
+}
+
+// sortOrders maps the sort parameter to the indexer's sort order
+var sortOrders = map[string][]string{
+	"relevance": nil,
+	"newest":    {"-Modified", "-_score"},
+	"oldest":    {"Modified", "-_score"},
+}
+
+// parseDate reads a date parameter, either a day (2006-01-02) or a RFC 3339 time
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// filters builds the filters and sort order given by the request parameters
+func (resp *Response) filters() (filters []indexer.Filter, sort []string, err error) {
+	modified := indexer.Filter{Field: "Modified"}
+	if resp.After != "" {
+		if modified.Start, err = parseDate(resp.After); err != nil {
+			return nil, nil, fmt.Errorf("invalid after date %q", resp.After)
+		}
+	}
+	if resp.Before != "" {
+		if modified.End, err = parseDate(resp.Before); err != nil {
+			return nil, nil, fmt.Errorf("invalid before date %q", resp.Before)
+		}
+		if len(resp.Before) == len("2006-01-02") {
+			// include the whole day
+			modified.End = modified.End.AddDate(0, 0, 1)
+		}
+	}
+	if !modified.Start.IsZero() || !modified.End.IsZero() {
+		filters = append(filters, modified)
+	}
+	if resp.Path != "" {
+		filters = append(filters, indexer.Filter{Field: "PathKeyword", Prefix: resp.Path})
+	}
+	if len(resp.Mime) > 0 {
+		filters = append(filters, indexer.Filter{Field: "MimeType", Values: resp.Mime})
+	}
+	if len(resp.Section) > 0 {
+		filters = append(filters, indexer.Filter{Field: "Section", Values: resp.Section})
+	}
+
+	sort, ok := sortOrders[resp.Sort]
+	if !ok && resp.Sort != "" {
+		return nil, nil, fmt.Errorf("invalid sort order %q", resp.Sort)
+	}
+	return filters, sort, nil
+}
+
+// facetRequests returns the facets shown next to the search results
+func facetRequests(now time.Time) map[string]indexer.FacetRequest {
+	yearAgo := now.AddDate(-1, 0, 0)
+	return map[string]indexer.FacetRequest{
+		"MimeType": {Field: "MimeType", Size: 10},
+		"Section":  {Field: "Section", Size: 10},
+		"Modified": {Field: "Modified", DateRanges: []indexer.DateRange{
+			{Name: "Past day", Start: now.AddDate(0, 0, -1)},
+			{Name: "Past week", Start: now.AddDate(0, 0, -7)},
+			{Name: "Past month", Start: now.AddDate(0, -1, 0)},
+			{Name: "Past year", Start: yearAgo},
+			{Name: "Older", End: yearAgo},
+		}},
+	}
+}
+
+// listParam returns the values of a parameter given comma separated or repeated
+func listParam(params []string) []string {
+	var values []string
+	for _, param := range params {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// search runs the query described by the request parameters, the returned
+// Response holds everything but the results. The status is 400 for invalid
+// queries or parameters and 500 when the index fails.
+func (s *Search) search(r *http.Request) (Response, []indexer.Hit, int) {
 	qry := r.URL.Query()
-	q := qry.Get("q")
-	from := 0
-	size := 100
+	resp := Response{
+		Query: qry.Get("q"),
+		From:  0,
+		Size:  defaultSize,
+	}
 	if f, err := strconv.Atoi(qry.Get("f")); err == nil {
-		from = f
+		resp.From = f
 	}
 	if s, err := strconv.Atoi(qry.Get("s")); err == nil {
-		size = s
+		resp.Size = s
+	}
+	resp.After = qry.Get("after")
+	resp.Before = qry.Get("before")
+	resp.Path = qry.Get("path")
+	resp.Sort = qry.Get("sort")
+	resp.Mime = listParam(qry["mime"])
+	resp.Section = listParam(qry["section"])
+	if resp.Query == "" {
+		return resp, nil, http.StatusOK
+	}
+	if resp.From < 0 || resp.Size < 0 {
+		resp.Error = fmt.Sprintf("invalid page f=%d s=%d", resp.From, resp.Size)
+		return resp, nil, http.StatusBadRequest
 	}
-	indexResult := s.Indexer.Search(q, from, size)
 
-	results := make([]Result, len(indexResult))
+	filters, sort, err := resp.filters()
+	if err != nil {
+		resp.Error = err.Error()
+		return resp, nil, http.StatusBadRequest
+	}
 
-	for i, result := range indexResult {
-		body := result.Body()
-		results[i] = Result{
-			Path:     result.Path(),
-			Title:    result.Title(),
-			Modified: result.Modified(),
-			Indexed:  result.Indexed(),
-			Json:     string(body),
-			From:     from,
-			Size:     size,
+	indexResult := s.Indexer.Search(indexer.SearchRequest{
+		Query:     resp.Query,
+		From:      resp.From,
+		Size:      resp.Size,
+		Filters:   filters,
+		Sort:      sort,
+		Highlight: s.highlightOptions(),
+		Boosts:    s.Boosts,
+		Facets:    facetRequests(time.Now()),
+	})
+	status := http.StatusOK
+	if err := indexResult.Err; err != nil {
+		resp.Error = err.Error()
+		status = http.StatusInternalServerError
+		var queryErr *indexer.QueryError
+		if errors.As(err, &queryErr) {
+			status = http.StatusBadRequest
 		}
 	}
+	resp.Total = indexResult.Total
+	resp.Took = indexResult.Took.String()
+	resp.Facets = indexResult.Facets
+	resp.Suggestion = indexResult.Suggestion
+
+	return resp, indexResult.Hits, status
+}
+
+// SearchJSON renders the search results in JSON format
+func (s *Search) SearchJSON(w http.ResponseWriter, r *http.Request) error {
+	resp, hits, status := s.search(r)
 
-	jresp, err := json.Marshal(results)
+	resp.Results = make([]Result, len(hits))
+	for i, result := range hits {
+		resp.Results[i] = Result{
+			Path:      result.Path(),
+			Title:     result.Title(),
+			TitleHTML: s.titleHTML(result),
+			Modified:  result.Modified(),
+			Indexed:   result.Indexed(),
+			Json:      s.snippet(result),
+			Score:     result.Score,
+			From:      resp.From,
+			Size:      resp.Size,
+		}
+	}
+
+	jresp, err := json.Marshal(resp)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return err
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	w.Write(jresp)
 	return err
 }
 
 // SearchHTML renders the search results in the HTML template
 func (s *Search) SearchHTML(w http.ResponseWriter, r *http.Request) error {
-	qry := r.URL.Query()
-	q := qry.Get("q")
-	from := 0
-	size := 100
-	if f, err := strconv.Atoi(qry.Get("f")); err == nil {
-		from = f
-	}
-	if s, err := strconv.Atoi(qry.Get("s")); err == nil {
-		size = s
-	}
-
-	indexResult := s.Indexer.Search(q, from, size)
+	resp, hits, status := s.search(r)
 
-	results := make([]Result, len(indexResult))
-
-	for i, result := range indexResult {
-		results[i] = Result{
-			Path:     result.Path(),
-			Title:    result.Title(),
-			Modified: result.Modified(),
-			Body:     template.HTML(result.Body()),
-			From:     from,
-			Size:     size,
+	resp.Results = make([]Result, len(hits))
+	for i, result := range hits {
+		resp.Results[i] = Result{
+			Path:      result.Path(),
+			Title:     result.Title(),
+			TitleHTML: s.titleHTML(result),
+			Modified:  result.Modified(),
+			Body:      template.HTML(s.snippet(result)),
+			Score:     result.Score,
+			From:      resp.From,
+			Size:      resp.Size,
 		}
 	}
 
@@ -130,8 +389,7 @@ func (s *Search) SearchHTML(w http.ResponseWriter, r *http.Request) error {
 			Req:  r,
 			URL:  r.URL,
 		},
-		Query:   q,
-		Results: results,
+		Response: resp,
 	}
 
 	var buf bytes.Buffer
@@ -141,15 +399,54 @@ func (s *Search) SearchHTML(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
 
 	buf.WriteTo(w)
 	return nil
 }
 
+// Suggestions is the structure for the completions of a partial query
+type Suggestions struct {
+	Query  string
+	Terms  []string
+	Titles []string
+}
+
+// SuggestJSON renders the completions of a partial query in JSON format
+func (s *Search) SuggestJSON(w http.ResponseWriter, r *http.Request) error {
+	qry := r.URL.Query()
+	resp := Suggestions{
+		Query:  qry.Get("q"),
+		Terms:  []string{},
+		Titles: []string{},
+	}
+	size := 10
+	if s, err := strconv.Atoi(qry.Get("s")); err == nil && s > 0 {
+		size = s
+	}
+
+	suggestions, err := s.Indexer.Suggest(resp.Query, size)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	resp.Terms = append(resp.Terms, suggestions.Terms...)
+	resp.Titles = append(resp.Titles, suggestions.Titles...)
+
+	jresp, err := json.Marshal(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jresp)
+	return nil
+}
+
 type QueryResults struct {
 	httpserver.Context
-	Query   string
-	Results []Result
+	Response
 }
 
 type searchResponseWriter struct {
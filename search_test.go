@@ -0,0 +1,586 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+		err   bool
+	}{
+		{"2021-03-04", time.Date(2021, 3, 4, 0, 0, 0, 0, time.Local), false},
+		{"2021-03-04T05:06:07Z", time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), false},
+		{"2021-03-04T05:06:07+08:00", time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", 8*3600)), false},
+		{"2021-13-04", time.Time{}, true},
+		{"04/03/2021", time.Time{}, true},
+		{"", time.Time{}, true},
+	}
+	for _, test := range tests {
+		got, err := parseDate(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("parseDate(%q) error %v", test.value, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("parseDate(%q) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestFilters(t *testing.T) {
+	day := time.Date(2021, 3, 4, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		resp    Response
+		filters []indexer.Filter
+		sort    []string
+		err     bool
+	}{
+		{"none", Response{}, nil, nil, false},
+		{"after", Response{After: "2021-03-04"},
+			[]indexer.Filter{{Field: "Modified", Start: day}}, nil, false},
+		{"before the end of the day", Response{Before: "2021-03-04"},
+			[]indexer.Filter{{Field: "Modified", End: day.AddDate(0, 0, 1)}}, nil, false},
+		{"before a time", Response{Before: "2021-03-04T05:06:07Z"},
+			[]indexer.Filter{{Field: "Modified", End: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)}}, nil, false},
+		{"between", Response{After: "2021-03-04", Before: "2021-03-04"},
+			[]indexer.Filter{{Field: "Modified", Start: day, End: day.AddDate(0, 0, 1)}}, nil, false},
+		{"path", Response{Path: "/blog/"},
+			[]indexer.Filter{{Field: "PathKeyword", Prefix: "/blog/"}}, nil, false},
+		{"mime types", Response{Mime: []string{"text/html", "application/pdf"}},
+			[]indexer.Filter{{Field: "MimeType", Values: []string{"text/html", "application/pdf"}}}, nil, false},
+		{"root section", Response{Section: []string{"/"}},
+			[]indexer.Filter{{Field: "Section", Values: []string{"/"}}}, nil, false},
+		{"all", Response{After: "2021-03-04", Path: "/blog/", Mime: []string{"text/html"}, Sort: "newest"},
+			[]indexer.Filter{
+				{Field: "Modified", Start: day},
+				{Field: "PathKeyword", Prefix: "/blog/"},
+				{Field: "MimeType", Values: []string{"text/html"}},
+			}, []string{"-Modified", "-_score"}, false},
+		{"relevance", Response{Sort: "relevance"}, nil, nil, false},
+		{"oldest", Response{Sort: "oldest"}, nil, []string{"Modified", "-_score"}, false},
+		{"invalid after", Response{After: "yesterday"}, nil, nil, true},
+		{"invalid before", Response{Before: "2021-02-30"}, nil, nil, true},
+		{"invalid sort", Response{Sort: "random"}, nil, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filters, sort, err := test.resp.filters()
+			if (err != nil) != test.err {
+				t.Fatalf("error %v", err)
+			}
+			if !reflect.DeepEqual(filters, test.filters) {
+				t.Errorf("got filters %v, want %v", filters, test.filters)
+			}
+			if !reflect.DeepEqual(sort, test.sort) {
+				t.Errorf("got sort %v, want %v", sort, test.sort)
+			}
+		})
+	}
+}
+
+// testRecord is an in-memory indexer.Record
+type testRecord struct {
+	path, fullPath, title, mimetype string
+	body                            []byte
+	modified                        time.Time
+	ignored                         bool
+	meta                            map[string]string
+}
+
+func newTestRecord(path string, body string) *testRecord {
+	return &testRecord{path: path, body: []byte(body), meta: make(map[string]string)}
+}
+
+func (r *testRecord) Write(p []byte) (int, error) {
+	r.body = append(r.body, p...)
+	return len(p), nil
+}
+func (r *testRecord) Path() string                { return r.path }
+func (r *testRecord) FullPath() string            { return r.fullPath }
+func (r *testRecord) SetFullPath(fullPath string) { r.fullPath = fullPath }
+func (r *testRecord) Title() string               { return r.title }
+func (r *testRecord) SetTitle(title string)       { r.title = title }
+func (r *testRecord) Body() []byte                { return r.body }
+func (r *testRecord) SetBody(body []byte)         { r.body = body }
+func (r *testRecord) SetModified(mod time.Time)   { r.modified = mod }
+func (r *testRecord) Modified() time.Time         { return r.modified }
+func (r *testRecord) Load() bool                  { return false }
+func (r *testRecord) Ignore()                     { r.ignored = true }
+func (r *testRecord) Ignored() bool               { return r.ignored }
+func (r *testRecord) Indexed() time.Time          { return time.Time{} }
+func (r *testRecord) MimeType() string            { return r.mimetype }
+func (r *testRecord) SetMimeType(mimetype string) { r.mimetype = mimetype }
+func (r *testRecord) Meta(key string) string      { return r.meta[key] }
+func (r *testRecord) SetMeta(key, value string)   { r.meta[key] = value }
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		description string
+		fragments   map[string][]string
+		want        string
+	}{
+		{"body fragments", "a long body", "a description",
+			map[string][]string{"Body": {"<mark>first</mark>", "<mark>second</mark>"}, "Description": {"<mark>desc</mark>"}},
+			"<mark>first</mark> … <mark>second</mark>"},
+		{"description fragment", "a long body", "a description",
+			map[string][]string{"Description": {"<mark>desc</mark>"}}, "<mark>desc</mark>"},
+		{"description", "a long body", "fish & chips", nil, "fish &amp; chips"},
+		{"beginning of the body", "  the   beginning\n of the body", "", nil, "the beginning of…"},
+		{"short body", "short", "", nil, "short"},
+		{"escaped body", "fish & chips <b> and more", "", nil, "fish &amp; chips &lt;b&gt;…"},
+	}
+	s := &Search{SnippetSize: 16}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := newTestRecord("/a.html", test.body)
+			if test.description != "" {
+				rec.SetMeta("description", test.description)
+			}
+			got := s.snippet(indexer.Hit{Record: rec, Fragments: test.fragments})
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		text string
+		size int
+		want string
+	}{
+		{"some text", 0, ""},
+		{"some text", 20, "some text"},
+		{"some text", 4, "some…"},
+		{" some\t\n  text ", 20, "some text"},
+		{"北京欢迎你", 2, "北京…"},
+		{"a &lt;b&gt; c", 20, "a &amp;lt;b&amp;gt; c"},
+		{"a <b> c", 20, "a &lt;b&gt; c"},
+	}
+	for _, test := range tests {
+		if got := extract(test.text, test.size); got != test.want {
+			t.Errorf("extract(%q, %d) = %q, want %q", test.text, test.size, got, test.want)
+		}
+	}
+}
+
+func TestLink(t *testing.T) {
+	resp := Response{Query: "fish & chips", Size: defaultSize, Path: "/blog/", Mime: []string{"text/html"}}
+	tests := []struct {
+		name  string
+		resp  Response
+		pairs []string
+		want  template.URL
+	}{
+		{"query", Response{Query: "fish & chips", Size: defaultSize}, nil, "?q=fish+%26+chips"},
+		{"size", Response{Query: "a", Size: 10}, nil, "?q=a&s=10"},
+		{"keeps the filters", resp, []string{"sort", "newest"},
+			"?mime=text%2Fhtml&path=%2Fblog%2F&q=fish+%26+chips&sort=newest"},
+		{"replaces a filter", resp, []string{"mime", "text/markdown"},
+			"?mime=text%2Fmarkdown&path=%2Fblog%2F&q=fish+%26+chips"},
+		{"drops a filter", resp, []string{"path", ""}, "?mime=text%2Fhtml&q=fish+%26+chips"},
+		// the root section holds the files at the root only, unlike the path /
+		{"root section", resp, []string{"section", "/"},
+			"?mime=text%2Fhtml&path=%2Fblog%2F&q=fish+%26+chips&section=%2F"},
+		{"keeps the sections", Response{Query: "a", Size: defaultSize, Section: []string{"docs", "blog"}}, nil,
+			"?q=a&section=docs&section=blog"},
+		// the results of the new query start over from the first one
+		{"replaces the query", Response{Query: "serch", From: 20, Size: defaultSize, Sort: "oldest"}, []string{"q", "search"},
+			"?q=search&sort=oldest"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.resp.Link(test.pairs...); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+	dayAgo := time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("", 8*3600))
+	dateTests := []struct {
+		start, end time.Time
+		want       template.URL
+	}{
+		{dayAgo, time.Time{}, "?after=2021-03-04T05%3A06%3A07%2B08%3A00&mime=text%2Fhtml&path=%2Fblog%2F&q=fish+%26+chips"},
+		{time.Time{}, dayAgo.UTC(), "?before=2021-03-03T21%3A06%3A07Z&mime=text%2Fhtml&path=%2Fblog%2F&q=fish+%26+chips"},
+	}
+	for _, test := range dateTests {
+		got := resp.DateLink(test.start, test.end)
+		if got != test.want {
+			t.Errorf("DateLink(%v, %v) = %q, want %q", test.start, test.end, got, test.want)
+		}
+		// the filter has the exact bounds of the facet, no whole day added
+		params, _ := url.ParseQuery(strings.TrimPrefix(string(got), "?"))
+		link := Response{After: params.Get("after"), Before: params.Get("before")}
+		filters, _, err := link.filters()
+		if err != nil || len(filters) != 1 || !filters[0].Start.Equal(test.start) || !filters[0].End.Equal(test.end) {
+			t.Errorf("DateLink(%v, %v) filters %v, %v", test.start, test.end, filters, err)
+		}
+	}
+}
+
+// newTestIndexer creates a bleve indexer in a temporary directory, removed
+// once the test is over
+func newTestIndexer(t *testing.T, config indexer.Config) indexer.Handler {
+	dir, err := os.MkdirTemp("", "caddy-search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the index is never closed, its background writes may outlive the test
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	config.IndexDirectory = dir
+	config.DbName = "index"
+	if config.Analyzer == "" {
+		config.Analyzer = "standard"
+	}
+	index, err := NewIndexer("bleve", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
+
+func TestSearchHTMLEscapesTitles(t *testing.T) {
+	index := newTestIndexer(t, indexer.Config{})
+	record := index.Record("/a.html")
+	record.SetTitle("<script>alert(1)</script> injected")
+	record.SetBody([]byte("some body"))
+	record.SetModified(time.Now())
+	index.Index(record)
+
+	tests := []struct {
+		name      string
+		query     string
+		highlight bool
+		want      string
+	}{
+		{"highlighted", "script", true, "&lt;<mark>script</mark>&gt;alert(1)&lt;/<mark>script</mark>&gt; injected"},
+		{"not highlighted", "injected", false, "&lt;script&gt;alert(1)&lt;/script&gt; injected"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Search{
+				Indexer:        index,
+				Template:       template.Must(template.New("search-results").Parse(defaultTemplate)),
+				HighlightTitle: test.highlight,
+				SnippetSize:    16,
+			}
+			w := httptest.NewRecorder()
+			if err := s.SearchHTML(w, httptest.NewRequest("GET", "/search?q="+test.query, nil)); err != nil {
+				t.Fatal(err)
+			}
+			got := w.Body.String()
+			if strings.Contains(got, "<script>alert") {
+				t.Errorf("unescaped title in %s", got)
+			}
+			if !strings.Contains(got, test.want) {
+				t.Errorf("title %q missing from %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestSearchHTMLEscapesDescriptions(t *testing.T) {
+	index := newTestIndexer(t, indexer.Config{})
+	record := index.Record("/a.html")
+	record.SetBody([]byte(`<html><head><title>page</title>
+<meta name="description" content="&lt;img src=x onerror=alert(1)&gt; description"></head>
+<body><p>some body</p></body></html>`))
+	record.SetModified(time.Now())
+	if _, err := extractHtml(record); err != nil {
+		t.Fatal(err)
+	}
+	index.Index(record)
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"highlighted", "description", "&lt;img src=x onerror=alert(1)&gt; <mark>description</mark>"},
+		{"not highlighted", "Title:page", "&lt;img src=x onerror=alert(1)&gt; description"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Search{
+				Indexer:  index,
+				Template: template.Must(template.New("search-results").Parse("{{range .Results}}{{.Body}}{{end}}")),
+			}
+			w := httptest.NewRecorder()
+			if err := s.SearchHTML(w, httptest.NewRequest("GET", "/search?q="+test.query, nil)); err != nil {
+				t.Fatal(err)
+			}
+			if got := w.Body.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchHTMLEscapesBodies(t *testing.T) {
+	const text = "<img src=x onerror=alert> searched"
+	tests := []struct {
+		path     string
+		mimeType string
+		body     []byte
+	}{
+		{"/a.txt", "text/plain", []byte(text)},
+		{"/a.html", "text/html", []byte("<html><body><p>" + html.EscapeString(text) + "</p></body></html>")},
+		{"/a.md", "text/markdown", []byte("`" + text + "`")},
+		{"/a.pdf", "application/pdf", testPdf("/Title (A title)", text)},
+		{"/a.docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", testZip(
+			"word/document.xml", `<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>`+html.EscapeString(text)+`</w:t></w:r></w:p></w:body></w:document>`)},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			index := newTestIndexer(t, indexer.Config{})
+			record := index.Record(test.path)
+			record.SetBody(test.body)
+			record.SetMimeType(test.mimeType)
+			record.SetModified(time.Now())
+			if ok, err := LookupExtractor(test.mimeType).Extract(record); !ok || err != nil {
+				t.Fatalf("got %v, %v", ok, err)
+			}
+			index.Index(record)
+
+			s := &Search{
+				Indexer:  index,
+				Template: template.Must(template.New("search-results").Parse("{{range .Results}}{{.Body}}{{end}}")),
+			}
+			w := httptest.NewRecorder()
+			if err := s.SearchHTML(w, httptest.NewRequest("GET", "/search?q=searched", nil)); err != nil {
+				t.Fatal(err)
+			}
+			got := w.Body.String()
+			if want := "&lt;img src=x onerror=alert&gt; <mark>searched</mark>"; !strings.Contains(got, want) {
+				t.Errorf("got %q, want %q in it", got, want)
+			}
+		})
+	}
+}
+
+func TestSearchHTMLSuggestion(t *testing.T) {
+	index := newTestIndexer(t, indexer.Config{})
+	record := index.Record("/a.md")
+	record.SetTitle("a.md")
+	record.SetBody([]byte("search engine"))
+	record.SetModified(time.Now())
+	index.Index(record)
+
+	s := &Search{
+		Indexer:  index,
+		Template: template.Must(template.New("search-results").Parse(defaultTemplate)),
+	}
+	w := httptest.NewRecorder()
+	if err := s.SearchHTML(w, httptest.NewRequest("GET", "/search?q=serch", nil)); err != nil {
+		t.Fatal(err)
+	}
+	want := `Did you mean <a href="?q=search"><b>search</b></a>?`
+	if got := w.Body.String(); !strings.Contains(got, want) {
+		t.Errorf("suggestion %s missing from %s", want, got)
+	}
+}
+
+// searchHandler is an indexer answering every search with resp
+type searchHandler struct {
+	indexer.Handler
+	resp indexer.SearchResponse
+	req  *indexer.SearchRequest
+}
+
+func (h *searchHandler) Search(req indexer.SearchRequest) indexer.SearchResponse {
+	h.req = &req
+	return h.resp
+}
+
+func TestSearchJSON(t *testing.T) {
+	hits := []indexer.Hit{
+		{Record: newTestRecord("/a.md", "first"), Score: 2},
+		{Record: newTestRecord("/b.md", "second"), Score: 1},
+	}
+	tests := []struct {
+		name     string
+		query    string
+		resp     indexer.SearchResponse
+		status   int
+		searched bool
+		want     Response
+	}{
+		{"results", "q=text&f=10&s=2", indexer.SearchResponse{Hits: hits, Total: 42}, http.StatusOK, true,
+			Response{Query: "text", From: 10, Size: 2, Total: 42}},
+		{"default page", "q=text", indexer.SearchResponse{Total: 0}, http.StatusOK, true,
+			Response{Query: "text", From: 0, Size: 100}},
+		{"no query", "", indexer.SearchResponse{}, http.StatusOK, false,
+			Response{From: 0, Size: 100}},
+		{"invalid date", "q=text&after=yesterday", indexer.SearchResponse{}, http.StatusBadRequest, false,
+			Response{Query: "text", Size: 100, After: "yesterday", Error: `invalid after date "yesterday"`}},
+		{"invalid page", "q=text&s=-1", indexer.SearchResponse{}, http.StatusBadRequest, false,
+			Response{Query: "text", Size: -1, Error: "invalid page f=0 s=-1"}},
+		{"invalid query", "q=text", indexer.SearchResponse{Err: &indexer.QueryError{Err: errors.New("syntax error")}},
+			http.StatusBadRequest, true, Response{Query: "text", Size: 100, Error: "syntax error"}},
+		{"index failure", "q=text", indexer.SearchResponse{Err: errors.New("read error")},
+			http.StatusInternalServerError, true, Response{Query: "text", Size: 100, Error: "read error"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := &searchHandler{resp: test.resp}
+			s := &Search{Indexer: handler, SnippetSize: 16}
+			w := httptest.NewRecorder()
+			if err := s.SearchJSON(w, httptest.NewRequest("GET", "/search?"+test.query, nil)); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != test.status {
+				t.Errorf("got status %d, want %d", w.Code, test.status)
+			}
+			if (handler.req != nil) != test.searched {
+				t.Errorf("got search %v, want %v", handler.req != nil, test.searched)
+			}
+
+			var got Response
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Query != test.want.Query || got.From != test.want.From || got.Size != test.want.Size ||
+				got.Total != test.want.Total || got.Error != test.want.Error {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+			if len(got.Results) != len(test.resp.Hits) {
+				t.Fatalf("got %d results, want %d", len(got.Results), len(test.resp.Hits))
+			}
+			for n, result := range got.Results {
+				if result.Path != test.resp.Hits[n].Path() || result.From != test.want.From || result.Size != test.want.Size {
+					t.Errorf("got result %+v, want %v", result, test.resp.Hits[n].Path())
+				}
+			}
+			if handler.req != nil && (handler.req.From != test.want.From || handler.req.Size != test.want.Size) {
+				t.Errorf("searched from %d size %d, want %d and %d", handler.req.From, handler.req.Size, test.want.From, test.want.Size)
+			}
+		})
+	}
+}
+
+func TestSearchHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		resp   indexer.SearchResponse
+		status int
+		want   string
+	}{
+		{"results", indexer.SearchResponse{Hits: []indexer.Hit{{Record: newTestRecord("/a.md", "first")}}, Total: 1},
+			http.StatusOK, "1 /a.md"},
+		{"index failure", indexer.SearchResponse{Err: errors.New("read error")}, http.StatusInternalServerError, "0 read error"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Search{
+				Indexer:  &searchHandler{resp: test.resp},
+				Template: template.Must(template.New("search-results").Parse("{{.Total}} {{range .Results}}{{.Path}} {{end}}{{.Error}}")),
+			}
+			w := httptest.NewRecorder()
+			if err := s.SearchHTML(w, httptest.NewRequest("GET", "/search?q=text", nil)); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != test.status {
+				t.Errorf("got status %d, want %d", w.Code, test.status)
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// suggestHandler is an indexer completing every query with suggestions, or failing with err
+type suggestHandler struct {
+	indexer.Handler
+	suggestions indexer.Suggestions
+	err         error
+	size        int
+}
+
+func (h *suggestHandler) Suggest(q string, size int) (indexer.Suggestions, error) {
+	h.size = size
+	return h.suggestions, h.err
+}
+
+func TestSuggestJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		handler *suggestHandler
+		status  int
+		size    int
+		want    string
+	}{
+		{"suggestions", "q=" + url.QueryEscape("北") + "&s=5", &suggestHandler{suggestions: indexer.Suggestions{Terms: []string{"北京"}, Titles: []string{"北京大学"}}},
+			http.StatusOK, 5, `{"Query":"北","Terms":["北京"],"Titles":["北京大学"]}`},
+		{"none", "q=x", &suggestHandler{}, http.StatusOK, 10, `{"Query":"x","Terms":[],"Titles":[]}`},
+		{"invalid size", "q=x&s=-1", &suggestHandler{}, http.StatusOK, 10, `{"Query":"x","Terms":[],"Titles":[]}`},
+		{"index failure", "q=x", &suggestHandler{err: errors.New("read error")}, http.StatusInternalServerError, 10, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Search{Indexer: test.handler}
+			w := httptest.NewRecorder()
+			err := s.SuggestJSON(w, httptest.NewRequest("GET", "/search/suggest?"+test.query, nil))
+			if (err != nil) != (test.handler.err != nil) {
+				t.Fatalf("got error %v, want %v", err, test.handler.err)
+			}
+			if w.Code != test.status {
+				t.Errorf("got status %d, want %d", w.Code, test.status)
+			}
+			if test.handler.size != test.size {
+				t.Errorf("got size %d, want %d", test.handler.size, test.size)
+			}
+			if got := w.Body.String(); got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+			if test.want != "" && w.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("got content type %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestSuggestJSONSego(t *testing.T) {
+	index := newTestIndexer(t, indexer.Config{Analyzer: "sego"})
+	record := index.Record("/trip.md")
+	record.SetTitle("北京旅游指南")
+	record.SetBody([]byte("我想去北京旅游"))
+	record.SetModified(time.Now())
+	index.Index(record)
+
+	s := &Search{Indexer: index}
+	w := httptest.NewRecorder()
+	if err := s.SuggestJSON(w, httptest.NewRequest("GET", "/search/suggest?q="+url.QueryEscape("我想去北"), nil)); err != nil {
+		t.Fatal(err)
+	}
+	var got Suggestions
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Terms) == 0 || got.Terms[0] != "我想去北京" {
+		t.Errorf("got terms %v, want 我想去北京 first", got.Terms)
+	}
+	if !reflect.DeepEqual(got.Titles, []string{"北京旅游指南"}) {
+		t.Errorf("got titles %v, want [北京旅游指南]", got.Titles)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -39,8 +40,29 @@ type Search struct {
 	SiteRoot        string
 	NumWorkers      int
 	Analyzer        string
+	Languages       []string
+	CustomAnalyzers map[string]indexer.CustomAnalyzer
+	TokenFilters    map[string]indexer.Component
+	TokenMaps       map[string][]string
+	FieldAnalyzers  map[string]string
+	SegoDicts       []string
+	SegoDictReload  bool
+	SegoDictReindex bool
+	SegoDictCache   string
+	SegoHMM         bool
+	SegoConvert     string
+	SegoPosDrop     []string
+	SegoPosKeep     []string
+	Pinyin          bool
 	MaxSizeFile     int
 	FileWatcher     bool
+	Fragments       int
+	FragmentSize    int
+	HighlightBefore string
+	HighlightAfter  string
+	HighlightTitle  bool
+	SnippetSize     int
+	Boosts          map[string]float64
 
 	Indexer      indexer.Handler
 	IndexManager *IndexerManager
@@ -86,9 +108,22 @@ func (search *Search) Provision(ctx caddy.Context) (err error) {
 	search.IncludePaths = ConvertToRegExp(search.IncludePathsStr)
 
 	index, err := NewIndexer(search.Engine, indexer.Config{
-		DbName:         search.DbName,
-		IndexDirectory: search.IndexDirectory,
-	}, search.Analyzer)
+		DbName:          search.DbName,
+		IndexDirectory:  search.IndexDirectory,
+		Analyzer:        search.Analyzer,
+		Languages:       search.Languages,
+		CustomAnalyzers: search.CustomAnalyzers,
+		TokenFilters:    search.TokenFilters,
+		TokenMaps:       search.TokenMaps,
+		FieldAnalyzers:  search.FieldAnalyzers,
+		SegoDicts:       search.SegoDicts,
+		SegoCacheDir:    search.SegoDictCache,
+		SegoHMM:         search.SegoHMM,
+		SegoConvert:     search.SegoConvert,
+		SegoPosDrop:     search.SegoPosDrop,
+		SegoPosKeep:     search.SegoPosKeep,
+		Pinyin:          search.Pinyin,
+	})
 
 	if err != nil {
 		return err
@@ -104,19 +139,24 @@ func (search *Search) Provision(ctx caddy.Context) (err error) {
 	search.IndexManager = ppl
 
 	go func() {
-		ScanToPipe(search.SiteRoot, ppl, index)
+		_, walked := ScanToPipe(search.SiteRoot, ppl, index)
+		PurgeStale(walked, ppl, index)
 		if search.Expire <= 0 {
 			return
 		}
 		expire := time.NewTicker(search.Expire)
 		for !search.closed {
 			<-expire.C
-			ScanToPipe(search.SiteRoot, ppl, index)
+			_, walked = ScanToPipe(search.SiteRoot, ppl, index)
+			PurgeStale(walked, ppl, index)
 		}
 	}()
 	if search.FileWatcher {
 		search.StartWatcher(search.SiteRoot, ppl, index)
 	}
+	if reloader, ok := index.(indexer.DictionaryReloader); ok && search.SegoDictReload && len(search.SegoDicts) > 0 {
+		search.StartDictWatcher(reloader, search.SegoDictReindex)
+	}
 
 	return nil
 }
@@ -142,68 +182,74 @@ func (m *Search) Cleanup() error {
 	m.closed = true
 	return nil
 }
+
+// fileWatcher indexes the changes reported by watcher to the files under root
+type fileWatcher struct {
+	root         string
+	watcher      *fsnotify.Watcher
+	indexManager *IndexerManager
+	index        indexer.Handler
+
+	// queued holds the files to index once they are left unchanged
+	lk     sync.Mutex
+	queued map[string]bool
+
+	// watched holds the directories added to the watcher, so that removed or
+	// renamed directories can be told apart from files once they are gone.
+	wlk     sync.Mutex
+	watched map[string]bool
+
+	prevFile string
+}
+
+func newFileWatcher(root string, watcher *fsnotify.Watcher, indexManager *IndexerManager, index indexer.Handler) *fileWatcher {
+	return &fileWatcher{
+		root:         root,
+		watcher:      watcher,
+		indexManager: indexManager,
+		index:        index,
+		queued:       make(map[string]bool),
+		watched:      make(map[string]bool),
+	}
+}
+
 func (m *Search) StartWatcher(fp string, indexManager *IndexerManager, index indexer.Handler) {
 	absPath, _ := filepath.Abs(fp)
 
-	dealwith := func(path string) {
-		log.Printf("Watcher processes %v", path)
-		info, err := os.Stat(path)
-		if err != nil {
-			log.Printf("Ignore watcher error %v,%v", err, path)
-			return
-		}
-		if info.IsDir() {
-			return
-		}
-		reqPath, err := filepath.Rel(absPath, path)
-		if err != nil {
-			return
-		}
-		reqPath = "/" + reqPath
-		u, err := url.Parse(reqPath)
-		if err != nil {
-			log.Fatal(err)
-		}
-		reqPath = u.String()
-
-		if indexManager.ValidatePath(reqPath) {
-			record := index.Record(reqPath)
-			record.SetFullPath(path)
-			record.SetModified(info.ModTime())
-			indexManager.Feed(record)
-		}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Fatal(err)
 	}
+	w := newFileWatcher(absPath, watcher, indexManager, index)
+
 	//index file if the file is not modified for checkdur
 	const checkdur = 10 * time.Second
 
-	var lk sync.Mutex
-	set := make(map[string]int)
-
 	go func() {
 		log.Printf("Watcher queue starting...")
 		ticker := time.NewTicker(checkdur)
 		toscan := make([]string, 0)
 		for !m.closed {
 			<-ticker.C
-			lk.Lock()
-			for key := range set {
+			w.lk.Lock()
+			for key := range w.queued {
 				stat, err := os.Stat(key)
 				if err != nil {
 					log.Printf("Ignore watcher error %v,%v", err, key)
-					delete(set, key)
+					delete(w.queued, key)
 					continue
 				}
 				if time.Since(stat.ModTime()) < checkdur {
 					log.Printf("Watcher queue compare %v - %v = %v", time.Now().Format("2006-01-02 15:04:05"), stat.ModTime().Format("2006-01-02 15:04:05"), time.Since(stat.ModTime()))
 					continue
 				}
-				delete(set, key)
+				delete(w.queued, key)
 				toscan = append(toscan, key)
 			}
-			lk.Unlock()
+			w.lk.Unlock()
 			if len(toscan) > 0 {
 				for _, v := range toscan {
-					dealwith(v)
+					w.feed(v)
 				}
 				toscan = make([]string, 0)
 			}
@@ -211,27 +257,8 @@ func (m *Search) StartWatcher(fp string, indexManager *IndexerManager, index ind
 		log.Printf("Watcher queue exiting...")
 	}()
 
-	queuefile := func(path string) {
-		info, err := os.Stat(path)
-		if err != nil {
-			log.Printf("Ignore watcher error %v,%v", err, path)
-			return
-		}
-		if info.IsDir() {
-			return
-		}
-		lk.Lock()
-		set[path] = 1
-		lk.Unlock()
-	}
-
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatal(err)
-	}
 	go func() {
 		ticker := time.NewTicker(checkdur)
-		prevFile := ""
 		for !m.closed {
 			select {
 			case <-ticker.C:
@@ -240,14 +267,7 @@ func (m *Search) StartWatcher(fp string, indexManager *IndexerManager, index ind
 				if !ok {
 					return
 				}
-				//log.Println("event:", event)
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					if prevFile != event.Name {
-						log.Println("Modified: ", event.Name)
-					}
-					prevFile = event.Name
-					queuefile(event.Name)
-				}
+				w.handle(event)
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
@@ -262,7 +282,87 @@ func (m *Search) StartWatcher(fp string, indexManager *IndexerManager, index ind
 		log.Fatal(err)
 	}
 
-	filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
+	w.watchDir(absPath, false)
+}
+
+// handle dispatches an event of the watcher: removed or renamed files and
+// directories are deleted from the index, created directories are watched and
+// created or written files are queued. Dot-files are ignored.
+func (w *fileWatcher) handle(event fsnotify.Event) {
+	//log.Println("event:", event)
+	name := filepath.Base(event.Name)
+	if name == "" || name[0] == '.' {
+		return
+	}
+	switch {
+	case event.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+		w.remove(event.Name)
+	case event.Op&fsnotify.Create == fsnotify.Create:
+		info, err := os.Stat(event.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			log.Println("Created: ", event.Name)
+			w.watchDir(event.Name, true)
+			return
+		}
+		w.queue(event.Name)
+	case event.Op&fsnotify.Write == fsnotify.Write:
+		if w.prevFile != event.Name {
+			log.Println("Modified: ", event.Name)
+		}
+		w.prevFile = event.Name
+		w.queue(event.Name)
+	}
+}
+
+// feed feeds the file at path to the pipeline
+func (w *fileWatcher) feed(path string) {
+	log.Printf("Watcher processes %v", path)
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Printf("Ignore watcher error %v,%v", err, path)
+		return
+	}
+	if info.IsDir() {
+		return
+	}
+	reqPath, err := requestPath(w.root, path)
+	if err != nil {
+		return
+	}
+
+	if w.indexManager.ValidatePath(reqPath) {
+		record := w.index.Record(reqPath)
+		record.SetFullPath(path)
+		record.SetModified(info.ModTime())
+		w.indexManager.Feed(record)
+	}
+}
+
+// queue queues the file at path to be fed once it is left unchanged
+func (w *fileWatcher) queue(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Printf("Ignore watcher error %v,%v", err, path)
+		return
+	}
+	if info.IsDir() {
+		return
+	}
+	w.lk.Lock()
+	w.queued[path] = true
+	w.lk.Unlock()
+}
+
+// watchDir adds dir and all its subdirectories to the watcher, queueing
+// the files found when queue is true.
+func (w *fileWatcher) watchDir(dir string, queue bool) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
 		if info.Name() == "." {
 			return nil
 		}
@@ -275,19 +375,150 @@ func (m *Search) StartWatcher(fp string, indexManager *IndexerManager, index ind
 		}
 
 		if info.IsDir() {
-			err1 := watcher.Add(path)
+			err1 := w.watcher.Add(path)
 			if err1 != nil {
-				log.Fatal(err1)
+				log.Printf("Ignore watcher error %v,%v", err1, path)
+				return nil
 			}
+			w.wlk.Lock()
+			w.watched[path] = true
+			w.wlk.Unlock()
+		} else if queue {
+			w.queue(path)
 		}
 		return nil
 	})
+}
 
+// remove deletes the file or the directory at path from the index
+func (w *fileWatcher) remove(path string) {
+	w.lk.Lock()
+	delete(w.queued, path)
+	w.lk.Unlock()
+
+	w.wlk.Lock()
+	isDir := w.watched[path]
+	if isDir {
+		// the subdirectories are gone along with it
+		for dir := range w.watched {
+			if dir == path || strings.HasPrefix(dir, path+string(filepath.Separator)) {
+				delete(w.watched, dir)
+				w.watcher.Remove(dir)
+			}
+		}
+	}
+	w.wlk.Unlock()
+
+	reqPath, err := requestPath(w.root, path)
+	if err != nil {
+		return
+	}
+	if isDir {
+		// the files of a renamed directory are not reported one by one
+		log.Println("Removed: ", path)
+		if err := w.index.DeletePrefix(strings.TrimSuffix(reqPath, "/") + "/"); err != nil {
+			log.Printf("Ignore watcher error %v,%v", err, path)
+		}
+		return
+	}
+	if w.indexManager.ValidatePath(reqPath) {
+		log.Println("Removed: ", path)
+		if err := w.index.Delete(reqPath); err != nil {
+			log.Printf("Ignore watcher error %v,%v", err, path)
+		}
+	}
 }
 
-// ScanToPipe ...
-func ScanToPipe(fp string, indexManager *IndexerManager, index indexer.Handler) indexer.Record {
+// StartDictWatcher reloads the dictionaries of the indexer when their files
+// change and, when reindex is true, indexes again the documents holding the
+// words which changed
+func (m *Search) StartDictWatcher(reloader indexer.DictionaryReloader, reindex bool) {
+	// reload once the files are left unchanged for quietdur, editors write them in several steps
+	const quietdur = 2 * time.Second
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Ignore dictionary watcher error %v", err)
+		return
+	}
+	files := make(map[string]bool)
+	for _, file := range reloader.Dictionaries() {
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			log.Printf("Ignore dictionary watcher error %v,%v", err, file)
+			continue
+		}
+		files[absFile] = true
+		// watch the directory, as editors replace the files by renaming
+		if err := watcher.Add(filepath.Dir(absFile)); err != nil {
+			log.Printf("Ignore dictionary watcher error %v,%v", err, file)
+		}
+	}
+
+	reload := func() {
+		words, err := reloader.ReloadDictionaries()
+		if err != nil {
+			log.Printf("Ignore dictionary reload error %v", err)
+			return
+		}
+		log.Printf("Dictionaries reloaded, %v words changed", len(words))
+		if !reindex || len(words) == 0 {
+			return
+		}
+		n, err := reloader.Reindex(words)
+		if err != nil {
+			log.Printf("Ignore reindex error %v", err)
+			return
+		}
+		log.Printf("Reindexed %v documents", n)
+	}
+
+	go func() {
+		defer watcher.Close()
+		ticker := time.NewTicker(quietdur)
+		var changed time.Time
+		for !m.closed {
+			select {
+			case <-ticker.C:
+				if !changed.IsZero() && time.Since(changed) >= quietdur {
+					changed = time.Time{}
+					reload()
+				}
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if files[event.Name] && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					changed = time.Now()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("error:", err)
+			}
+		}
+	}()
+}
+
+// requestPath converts a file under root into the request path it is indexed as
+func requestPath(root string, path string) (string, error) {
+	reqPath, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse("/" + filepath.ToSlash(reqPath))
+	if err != nil {
+		return "", err
+	}
+	return GetUrlPath(u), nil
+}
+
+// ScanToPipe feeds every indexable file under fp to the pipeline, it returns
+// the last record fed and the request paths of all the records fed
+func ScanToPipe(fp string, indexManager *IndexerManager, index indexer.Handler) (indexer.Record, map[string]bool) {
 	var last indexer.Record
+	walked := make(map[string]bool)
 	absPath, _ := filepath.Abs(fp)
 	filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
 		if info.Name() == "." {
@@ -302,16 +533,10 @@ func ScanToPipe(fp string, indexManager *IndexerManager, index indexer.Handler)
 		}
 
 		if !info.IsDir() {
-			reqPath, err := filepath.Rel(absPath, path)
+			reqPath, err := requestPath(absPath, path)
 			if err != nil {
 				return nil
 			}
-			reqPath = "/" + reqPath
-			u, err := url.Parse(reqPath)
-			if err != nil {
-				log.Fatal(err)
-			}
-			reqPath = GetUrlPath(u)
 
 			if indexManager.ValidatePath(reqPath) {
 				record := index.Record(reqPath)
@@ -319,13 +544,54 @@ func ScanToPipe(fp string, indexManager *IndexerManager, index indexer.Handler)
 				record.SetModified(info.ModTime())
 				indexManager.Feed(record)
 				last = record
+				walked[reqPath] = true
 			}
 		}
 
 		return nil
 	})
 
-	return last
+	return last, walked
+}
+
+// PurgeStale removes the file backed documents which were not fed by the last
+// full scan and whose source file is gone or is no longer to be indexed.
+// Documents captured from dynamic responses are left alone.
+func PurgeStale(walked map[string]bool, indexManager *IndexerManager, index indexer.Handler) {
+	docs, err := index.FileBacked()
+	if err != nil {
+		log.Printf("Ignore purge error %v", err)
+		return
+	}
+	for reqPath, fullPath := range docs {
+		filePath := documentPath(reqPath, fullPath, docs)
+		if walked[filePath] {
+			continue
+		}
+		// the file may have been created after the walk passed by
+		if _, err := os.Stat(fullPath); err == nil && indexManager.ValidatePath(filePath) {
+			continue
+		}
+		log.Println("Purged: ", reqPath)
+		if err := index.Delete(reqPath); err != nil {
+			log.Printf("Ignore purge error %v,%v", err, reqPath)
+		}
+	}
+}
+
+// documentPath returns the path of the document which reqPath is a part of
+// (path#part), reqPath itself if none. The parts share the file of their
+// document, unlike the files whose name holds a #.
+func documentPath(reqPath string, fullPath string, docs map[string]string) string {
+	for n, c := range reqPath {
+		if c != '#' {
+			continue
+		}
+		if parent, ok := docs[reqPath[:n]]; ok && parent == fullPath {
+			return reqPath[:n]
+		}
+	}
+	return reqPath
 }
 
 func GetUrlPath(u *url.URL) string {
@@ -340,11 +606,11 @@ func GetUrlPath(u *url.URL) string {
 }
 
 // NewIndexer creates a new Indexer with the received config
-func NewIndexer(engine string, config indexer.Config, analyzer string) (index indexer.Handler, err error) {
+func NewIndexer(engine string, config indexer.Config) (index indexer.Handler, err error) {
 	name := filepath.Clean(config.IndexDirectory + string(filepath.Separator) + config.DbName)
 	switch engine {
 	default:
-		index, err = bleve.New(name, analyzer)
+		index, err = bleve.New(name, config)
 	}
 	return
 }
@@ -362,6 +628,22 @@ func (m *Search) UnmarshalCaddyfile(c *caddyfile.Dispenser) error {
 	m.NumWorkers = 0
 	m.Analyzer = "standard"
 	m.MaxSizeFile = 1024 * 1024 * 50
+	m.Fragments = 1
+	m.FragmentSize = 200
+	m.HighlightBefore = "<mark>"
+	m.HighlightAfter = "</mark>"
+	m.HighlightTitle = true
+	m.SnippetSize = 300
+	m.Boosts = map[string]float64{
+		"Title":    5,
+		"Headings": 3,
+		"Path":     2,
+		"Body":     1,
+	}
+	m.CustomAnalyzers = make(map[string]indexer.CustomAnalyzer)
+	m.TokenFilters = make(map[string]indexer.Component)
+	m.TokenMaps = make(map[string][]string)
+	m.FieldAnalyzers = make(map[string]string)
 
 	incPaths := []string{}
 	excPaths := []string{}
@@ -457,6 +739,183 @@ func (m *Search) UnmarshalCaddyfile(c *caddyfile.Dispenser) error {
 					return c.ArgErr()
 				}
 				m.Analyzer = c.Val()
+			case "languages":
+				m.Languages = c.RemainingArgs()
+				if len(m.Languages) == 0 {
+					return c.ArgErr()
+				}
+			case "fragments":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				val, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return err
+				}
+				m.Fragments = val
+			case "fragmentsize":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				val, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return err
+				}
+				m.FragmentSize = val
+			case "highlight":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return c.ArgErr()
+				}
+				m.HighlightBefore = args[0]
+				m.HighlightAfter = args[1]
+			case "highlighttitle":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				v, err := strconv.ParseBool(c.Val())
+				if err != nil {
+					return err
+				}
+				m.HighlightTitle = v
+			case "snippetsize":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				val, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return err
+				}
+				m.SnippetSize = val
+			case "boost":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return c.ArgErr()
+				}
+				boost, err := strconv.ParseFloat(args[1], 64)
+				if err != nil {
+					return err
+				}
+				if boost == 0 {
+					delete(m.Boosts, args[0])
+				} else {
+					m.Boosts[args[0]] = boost
+				}
+			case "customanalyzer":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				name := c.Val()
+				analyzer := indexer.CustomAnalyzer{Tokenizer: "unicode"}
+				for nesting := c.Nesting(); c.NextBlock(nesting); {
+					switch c.Val() {
+					case "tokenizer":
+						if !c.NextArg() {
+							return c.ArgErr()
+						}
+						analyzer.Tokenizer = c.Val()
+					case "charfilters":
+						analyzer.CharFilters = c.RemainingArgs()
+					case "tokenfilters":
+						analyzer.TokenFilters = c.RemainingArgs()
+					default:
+						return c.Errf("unknown analyzer option %v", c.Val())
+					}
+				}
+				m.CustomAnalyzers[name] = analyzer
+			case "tokenfilter":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return c.ArgErr()
+				}
+				filter := indexer.Component{Type: args[1], Options: make(map[string]interface{})}
+				for nesting := c.Nesting(); c.NextBlock(nesting); {
+					key := c.Val()
+					values := c.RemainingArgs()
+					if len(values) == 0 {
+						return c.ArgErr()
+					}
+					filter.Options[key] = optionValue(values)
+				}
+				m.TokenFilters[args[0]] = filter
+			case "tokenmap":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return c.ArgErr()
+				}
+				m.TokenMaps[args[0]] = args[1:]
+			case "fieldanalyzer":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return c.ArgErr()
+				}
+				m.FieldAnalyzers[args[0]] = args[1]
+			case "sego_dict":
+				files := c.RemainingArgs()
+				if len(files) == 0 {
+					return c.ArgErr()
+				}
+				for _, file := range files {
+					if _, err := os.Stat(file); err != nil {
+						return c.Errf("[search]: invalid sego dictionary %v", err)
+					}
+				}
+				m.SegoDicts = append(m.SegoDicts, files...)
+			case "sego_dict_reload":
+				m.SegoDictReload = true
+				if c.NextArg() {
+					if c.Val() != "reindex" {
+						return c.ArgErr()
+					}
+					m.SegoDictReindex = true
+				}
+			case "sego_dict_cache":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				m.SegoDictCache = c.Val()
+			case "sego_hmm":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				v, err := strconv.ParseBool(c.Val())
+				if err != nil {
+					return err
+				}
+				m.SegoHMM = v
+			case "sego_convert":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				switch c.Val() {
+				case "t2s", "s2t":
+					m.SegoConvert = c.Val()
+				case "off":
+					m.SegoConvert = ""
+				default:
+					return c.ArgErr()
+				}
+			case "sego_pos_drop":
+				tags := c.RemainingArgs()
+				if len(tags) == 0 {
+					return c.ArgErr()
+				}
+				m.SegoPosDrop = append(m.SegoPosDrop, tags...)
+			case "sego_pos_keep":
+				tags := c.RemainingArgs()
+				if len(tags) == 0 {
+					return c.ArgErr()
+				}
+				m.SegoPosKeep = append(m.SegoPosKeep, tags...)
+			case "pinyin":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				v, err := strconv.ParseBool(c.Val())
+				if err != nil {
+					return err
+				}
+				m.Pinyin = v
 			case "template":
 				if c.NextArg() {
 					m.TemplateRaw = c.Val()
@@ -491,6 +950,13 @@ func (m *Search) UnmarshalCaddyfile(c *caddyfile.Dispenser) error {
 		}
 	}
 
+	switch m.SegoDictCache {
+	case "":
+		m.SegoDictCache = filepath.Join(m.IndexDirectory, "sego")
+	case "off":
+		m.SegoDictCache = ""
+	}
+
 	if m.NumWorkers <= 0 {
 		nc := runtime.NumCPU() / 2
 		if nc <= 0 {
@@ -511,6 +977,25 @@ var (
 	_ caddy.CleanerUpper          = (*Search)(nil)
 )
 
+// optionValue converts the values of an analysis component option, numbers
+// and booleans are typed and several values make a list
+func optionValue(values []string) interface{} {
+	typed := make([]interface{}, len(values))
+	for n, value := range values {
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			typed[n] = number
+		} else if value == "true" || value == "false" {
+			typed[n] = value == "true"
+		} else {
+			typed[n] = value
+		}
+	}
+	if len(typed) == 1 {
+		return typed[0]
+	}
+	return typed
+}
+
 // ConvertToRegExp compile a string regular expression to multiple *regexp.Regexp instances
 func ConvertToRegExp(rexp []string) (r []*regexp.Regexp) {
 	r = make([]*regexp.Regexp, 0)
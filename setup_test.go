@@ -0,0 +1,302 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddy-search/indexer"
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileBackedHandler is an indexer holding file backed documents only
+type fileBackedHandler struct {
+	indexer.Handler
+	docs map[string]string
+}
+
+func (h *fileBackedHandler) FileBacked() (map[string]string, error) {
+	return h.docs, nil
+}
+
+func (h *fileBackedHandler) Delete(path string) error {
+	delete(h.docs, path)
+	return nil
+}
+
+func TestPurgeStale(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"book.epub", "a#b.md", "kept.md", "late.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("text"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		name   string
+		docs   map[string]string
+		walked []string
+		want   []string
+	}{
+		{"walked", map[string]string{"/kept.md": file("kept.md")}, []string{"/kept.md"}, []string{"/kept.md"}},
+		{"created after the walk", map[string]string{"/late.md": file("late.md")}, nil, []string{"/late.md"}},
+		{"gone", map[string]string{"/gone.md": file("gone.md"), "/kept.md": file("kept.md")}, []string{"/kept.md"}, []string{"/kept.md"}},
+		{"excluded", map[string]string{"/kept.txt": file("kept.md")}, nil, nil},
+		{"book parts", map[string]string{
+			"/book.epub":          file("book.epub"),
+			"/book.epub#ch1.html": file("book.epub"),
+			"/book.epub#ch2.html": file("book.epub"),
+		}, []string{"/book.epub"}, []string{"/book.epub", "/book.epub#ch1.html", "/book.epub#ch2.html"}},
+		{"parts of a gone book", map[string]string{
+			"/old.epub":          file("old.epub"),
+			"/old.epub#ch1.html": file("old.epub"),
+		}, nil, nil},
+		{"file named with #", map[string]string{
+			"/a":      file("a"),
+			"/a#b.md": file("a#b.md"),
+		}, []string{"/a#b.md"}, []string{"/a#b.md"}},
+		{"gone file named with #", map[string]string{
+			"/a#c.md":  file("a#c.md"),
+			"/kept.md": file("kept.md"),
+		}, []string{"/kept.md"}, []string{"/kept.md"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := &fileBackedHandler{docs: test.docs}
+			manager := &IndexerManager{config: &Search{
+				IncludePaths: []*regexp.Regexp{regexp.MustCompile(`\.(md|epub)$`)},
+			}}
+			walked := make(map[string]bool)
+			for _, path := range test.walked {
+				walked[path] = true
+			}
+
+			PurgeStale(walked, manager, handler)
+
+			var got []string
+			for path := range handler.docs {
+				got = append(got, path)
+			}
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// deletingHandler is an indexer recording the deletions
+type deletingHandler struct {
+	indexer.Handler
+	deleted  []string
+	prefixes []string
+}
+
+func (h *deletingHandler) Delete(path string) error {
+	h.deleted = append(h.deleted, path)
+	return nil
+}
+
+func (h *deletingHandler) DeletePrefix(prefix string) error {
+	h.prefixes = append(h.prefixes, prefix)
+	return nil
+}
+
+func TestFileWatcherHandle(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		// change changes the tree under root and returns the event reporting it
+		change   func(t *testing.T, root string) fsnotify.Event
+		deleted  []string
+		prefixes []string
+		watched  []string
+		queued   []string
+	}{
+		{"deleted file", []string{"a.md", "b.md"}, func(t *testing.T, root string) fsnotify.Event {
+			removeFile(t, filepath.Join(root, "a.md"))
+			return fsnotify.Event{Name: filepath.Join(root, "a.md"), Op: fsnotify.Remove}
+		}, []string{"/a.md"}, nil, []string{""}, nil},
+		{"renamed directory", []string{"dir/a.md", "dir/sub/b.md", "other/c.md"}, func(t *testing.T, root string) fsnotify.Event {
+			if err := os.Rename(filepath.Join(root, "dir"), filepath.Join(root, ".moved")); err != nil {
+				t.Fatal(err)
+			}
+			return fsnotify.Event{Name: filepath.Join(root, "dir"), Op: fsnotify.Rename}
+		}, nil, []string{"/dir/"}, []string{"", "other"}, nil},
+		{"created directory", []string{"a.md"}, func(t *testing.T, root string) fsnotify.Event {
+			for _, name := range []string{"dir/b.md", "dir/sub/c.md", "dir/.d.md", "dir/.git/e.md"} {
+				writeFile(t, filepath.Join(root, name))
+			}
+			return fsnotify.Event{Name: filepath.Join(root, "dir"), Op: fsnotify.Create}
+		}, nil, nil, []string{"", "dir", "dir/sub"}, []string{"dir/b.md", "dir/sub/c.md"}},
+		{"dot-file", []string{".a.md", ".git/b.md"}, func(t *testing.T, root string) fsnotify.Event {
+			removeFile(t, filepath.Join(root, ".a.md"))
+			return fsnotify.Event{Name: filepath.Join(root, ".a.md"), Op: fsnotify.Remove}
+		}, nil, nil, []string{""}, nil},
+		{"created dot-directory", nil, func(t *testing.T, root string) fsnotify.Event {
+			writeFile(t, filepath.Join(root, ".git", "a.md"))
+			return fsnotify.Event{Name: filepath.Join(root, ".git"), Op: fsnotify.Create}
+		}, nil, nil, []string{""}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, name := range test.files {
+				writeFile(t, filepath.Join(root, name))
+			}
+			watcher, err := fsnotify.NewWatcher()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer watcher.Close()
+			handler := &deletingHandler{}
+			manager := &IndexerManager{config: &Search{
+				IncludePaths: []*regexp.Regexp{regexp.MustCompile(`\.md$`)},
+			}}
+			w := newFileWatcher(root, watcher, manager, handler)
+			w.watchDir(root, false)
+
+			w.handle(test.change(t, root))
+
+			if !reflect.DeepEqual(handler.deleted, test.deleted) {
+				t.Errorf("got deleted %v, want %v", handler.deleted, test.deleted)
+			}
+			if !reflect.DeepEqual(handler.prefixes, test.prefixes) {
+				t.Errorf("got deleted prefixes %v, want %v", handler.prefixes, test.prefixes)
+			}
+			relative := func(paths map[string]bool) []string {
+				var rel []string
+				for path := range paths {
+					r, _ := filepath.Rel(root, path)
+					if r == "." {
+						r = ""
+					}
+					rel = append(rel, filepath.ToSlash(r))
+				}
+				sort.Strings(rel)
+				return rel
+			}
+			if got := relative(w.watched); !reflect.DeepEqual(got, test.watched) {
+				t.Errorf("got watched %q, want %q", got, test.watched)
+			}
+			if got := relative(w.queued); !reflect.DeepEqual(got, test.queued) {
+				t.Errorf("got queued %q, want %q", got, test.queued)
+			}
+		})
+	}
+}
+
+// writeFile writes a file at path, creating its directories
+func writeFile(t *testing.T, path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func removeFile(t *testing.T, path string) {
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalCaddyfileAnalysis(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		analyzers map[string]indexer.CustomAnalyzer
+		filters   map[string]indexer.Component
+		maps      map[string][]string
+		fields    map[string]string
+		err       bool
+	}{
+		{"none", "search", map[string]indexer.CustomAnalyzer{}, map[string]indexer.Component{},
+			map[string][]string{}, map[string]string{}, false},
+		{"all", `search {
+			customanalyzer mine {
+				tokenizer whitespace
+				charfilters html
+				tokenfilters to_lower my_stop
+			}
+			customanalyzer plain
+			tokenfilter my_stop stop_tokens {
+				stop_token_map my_words
+			}
+			tokenfilter my_length length {
+				min 2
+				max 10
+			}
+			tokenfilter my_edge edge_ngram {
+				back false
+			}
+			tokenmap my_words the a an
+			fieldanalyzer Title mine
+		}`, map[string]indexer.CustomAnalyzer{
+			"mine":  {Tokenizer: "whitespace", CharFilters: []string{"html"}, TokenFilters: []string{"to_lower", "my_stop"}},
+			"plain": {Tokenizer: "unicode"},
+		}, map[string]indexer.Component{
+			"my_stop":   {Type: "stop_tokens", Options: map[string]interface{}{"stop_token_map": "my_words"}},
+			"my_length": {Type: "length", Options: map[string]interface{}{"min": 2.0, "max": 10.0}},
+			"my_edge":   {Type: "edge_ngram", Options: map[string]interface{}{"back": false}},
+		}, map[string][]string{"my_words": {"the", "a", "an"}}, map[string]string{"Title": "mine"}, false},
+		{"unknown analyzer option", "search {\n customanalyzer mine {\n filters to_lower\n }\n }", nil, nil, nil, nil, true},
+		{"analyzer without name", "search {\n customanalyzer\n }", nil, nil, nil, nil, true},
+		{"filter without type", "search {\n tokenfilter my_stop\n }", nil, nil, nil, nil, true},
+		{"filter option without value", "search {\n tokenfilter my_stop stop_tokens {\n stop_token_map\n }\n }", nil, nil, nil, nil, true},
+		{"empty token map", "search {\n tokenmap my_words\n }", nil, nil, nil, nil, true},
+		{"field without analyzer", "search {\n fieldanalyzer Title\n }", nil, nil, nil, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// keep the index directory out of the default one
+			input := test.input + "\nsearch {\n datadir " + t.TempDir() + "\n }"
+			m := &Search{}
+			err := m.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input))
+			if (err != nil) != test.err {
+				t.Fatalf("error %v", err)
+			}
+			if test.err {
+				return
+			}
+			if !reflect.DeepEqual(m.CustomAnalyzers, test.analyzers) {
+				t.Errorf("got analyzers %v, want %v", m.CustomAnalyzers, test.analyzers)
+			}
+			if !reflect.DeepEqual(m.TokenFilters, test.filters) {
+				t.Errorf("got token filters %v, want %v", m.TokenFilters, test.filters)
+			}
+			if !reflect.DeepEqual(m.TokenMaps, test.maps) {
+				t.Errorf("got token maps %v, want %v", m.TokenMaps, test.maps)
+			}
+			if !reflect.DeepEqual(m.FieldAnalyzers, test.fields) {
+				t.Errorf("got field analyzers %v, want %v", m.FieldAnalyzers, test.fields)
+			}
+		})
+	}
+}
+
+func TestOptionValue(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   interface{}
+	}{
+		{[]string{"word"}, "word"},
+		{[]string{"2"}, 2.0},
+		{[]string{"-1.5"}, -1.5},
+		{[]string{"true"}, true},
+		{[]string{"false"}, false},
+		{[]string{"a", "3", "true"}, []interface{}{"a", 3.0, true}},
+	}
+	for _, test := range tests {
+		if got := optionValue(test.values); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("optionValue(%q) = %#v, want %#v", test.values, got, test.want)
+		}
+	}
+}